@@ -36,6 +36,8 @@ type Visitor interface {
 	VisitPartial(*PartialStatement) any
 	VisitContent(*ContentStatement) any
 	VisitComment(*CommentStatement) any
+	VisitDecorator(*Decorator) any
+	VisitDecoratorBlock(*DecoratorBlock) any
 
 	// expressions
 	VisitExpression(*Expression) any
@@ -102,12 +104,21 @@ const (
 
 	// NodeHashPair is the hash pair node
 	NodeHashPair
+
+	// NodeDecoratorBlock is the decorator block node
+	NodeDecoratorBlock
+
+	// NodeDecorator is the decorator statement node
+	NodeDecorator
 )
 
 // Loc represents the position of a parsed node in source file.
 type Loc struct {
 	Pos  int // Byte position
 	Line int // Line number
+
+	Column int // 1-based column in runes, set by the parser
+	End    int // Byte position following the node, set by the parser
 }
 
 // Location returns itself, and permits struct includers to satisfy that part of Node interface.
@@ -167,7 +178,7 @@ type Program struct {
 func NewProgram(pos int, line int) *Program {
 	return &Program{
 		NodeType: NodeProgram,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -206,7 +217,7 @@ type MustacheStatement struct {
 func NewMustacheStatement(pos int, line int, unescaped bool) *MustacheStatement {
 	return &MustacheStatement{
 		NodeType:  NodeMustache,
-		Loc:       Loc{pos, line},
+		Loc:       Loc{Pos: pos, Line: line},
 		Unescaped: unescaped,
 	}
 }
@@ -235,6 +246,9 @@ type BlockStatement struct {
 	Program *Program
 	Inverse *Program
 
+	// raw block: {{{{helper}}}}...{{{{/helper}}}}
+	Raw bool
+
 	// whitespace management
 	OpenStrip    *Strip
 	InverseStrip *Strip
@@ -245,7 +259,7 @@ type BlockStatement struct {
 func NewBlockStatement(pos int, line int) *BlockStatement {
 	return &BlockStatement{
 		NodeType: NodeBlock,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -259,6 +273,74 @@ func (node *BlockStatement) Accept(visitor Visitor) any {
 	return visitor.VisitBlock(node)
 }
 
+//
+// Decorator
+//
+
+// Decorator represents a decorator statement node: {{* name args}}
+type Decorator struct {
+	NodeType
+	Loc
+
+	Expression *Expression
+
+	// whitespace management
+	Strip *Strip
+}
+
+// NewDecorator instanciates a new decorator node.
+func NewDecorator(pos int, line int) *Decorator {
+	return &Decorator{
+		NodeType: NodeDecorator,
+		Loc:      Loc{Pos: pos, Line: line},
+	}
+}
+
+// String returns a string representation of receiver that can be used for debugging.
+func (node *Decorator) String() string {
+	return fmt.Sprintf("Decorator{Pos: %d}", node.Loc.Pos)
+}
+
+// Accept is the receiver entry point for visitors.
+func (node *Decorator) Accept(visitor Visitor) any {
+	return visitor.VisitDecorator(node)
+}
+
+//
+// Decorator Block
+//
+
+// DecoratorBlock represents a decorator block node, like an inline partial definition: {{#*inline "name"}}...{{/inline}}
+type DecoratorBlock struct {
+	NodeType
+	Loc
+
+	Expression *Expression
+	Program    *Program
+
+	// whitespace management
+	OpenStrip  *Strip
+	CloseStrip *Strip
+}
+
+// NewDecoratorBlock instanciates a new decorator block node.
+func NewDecoratorBlock(pos int, line int) *DecoratorBlock {
+	return &DecoratorBlock{
+		NodeType: NodeDecoratorBlock,
+		Loc:      Loc{Pos: pos, Line: line},
+	}
+}
+
+// String returns a string representation of receiver that can be used for debugging.
+func (node *DecoratorBlock) String() string {
+	return fmt.Sprintf("DecoratorBlock{Pos: %d}", node.Loc.Pos)
+}
+
+// Accept is the receiver entry point for visitors.
+func (node *DecoratorBlock) Accept(visitor Visitor) any {
+	return visitor.VisitDecoratorBlock(node)
+}
+
 //
 // Partial Statement
 //
@@ -272,16 +354,20 @@ type PartialStatement struct {
 	Params []Node // [ Expression ... ]
 	Hash   *Hash
 
+	// partial block content: {{#> name}}...{{/name}}
+	Program *Program
+
 	// whitespace management
-	Strip  *Strip
-	Indent string
+	Strip      *Strip
+	CloseStrip *Strip
+	Indent     string
 }
 
 // NewPartialStatement instanciates a new partial node.
 func NewPartialStatement(pos int, line int) *PartialStatement {
 	return &PartialStatement{
 		NodeType: NodePartial,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -316,7 +402,7 @@ type ContentStatement struct {
 func NewContentStatement(pos int, line int, val string) *ContentStatement {
 	return &ContentStatement{
 		NodeType: NodeContent,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value:    val,
 		Original: val,
@@ -352,7 +438,7 @@ type CommentStatement struct {
 func NewCommentStatement(pos int, line int, val string) *CommentStatement {
 	return &CommentStatement{
 		NodeType: NodeComment,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value: val,
 	}
@@ -386,7 +472,7 @@ type Expression struct {
 func NewExpression(pos int, line int) *Expression {
 	return &Expression{
 		NodeType: NodeExpression,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -504,7 +590,7 @@ type SubExpression struct {
 func NewSubExpression(pos int, line int) *SubExpression {
 	return &SubExpression{
 		NodeType: NodeSubExpression,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -538,7 +624,7 @@ type PathExpression struct {
 func NewPathExpression(pos int, line int, data bool) *PathExpression {
 	result := &PathExpression{
 		NodeType: NodePath,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Data: data,
 	}
@@ -589,7 +675,7 @@ func (node *PathExpression) IsDataRoot() bool {
 // String Literal
 //
 
-// StringLiteral represents a string node.
+// StringLiteral represents a string node. Its location spans the string delimiters.
 type StringLiteral struct {
 	NodeType
 	Loc
@@ -601,7 +687,7 @@ type StringLiteral struct {
 func NewStringLiteral(pos int, line int, val string) *StringLiteral {
 	return &StringLiteral{
 		NodeType: NodeString,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value: val,
 	}
@@ -634,7 +720,7 @@ type BooleanLiteral struct {
 func NewBooleanLiteral(pos int, line int, val bool, original string) *BooleanLiteral {
 	return &BooleanLiteral{
 		NodeType: NodeBoolean,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value:    val,
 		Original: original,
@@ -678,7 +764,7 @@ type NumberLiteral struct {
 func NewNumberLiteral(pos int, line int, val float64, isInt bool, original string) *NumberLiteral {
 	return &NumberLiteral{
 		NodeType: NodeNumber,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 
 		Value:    val,
 		IsInt:    isInt,
@@ -730,7 +816,7 @@ type Hash struct {
 func NewHash(pos int, line int) *Hash {
 	return &Hash{
 		NodeType: NodeHash,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -770,7 +856,7 @@ type HashPair struct {
 func NewHashPair(pos int, line int) *HashPair {
 	return &HashPair{
 		NodeType: NodeHashPair,
-		Loc:      Loc{pos, line},
+		Loc:      Loc{Pos: pos, Line: line},
 	}
 }
 
@@ -10,8 +10,9 @@ type printVisitor struct {
 	buf   string
 	depth int
 
-	original bool
-	inBlock  bool
+	original  bool
+	inBlock   bool
+	locations bool
 }
 
 func newPrintVisitor() *printVisitor {
@@ -25,6 +26,15 @@ func Print(node Node) string {
 	return visitor.output()
 }
 
+// PrintWithLocations returns a string representation of given AST, with the location of each statement appended as
+// @line:column [pos:end].
+func PrintWithLocations(node Node) string {
+	visitor := newPrintVisitor()
+	visitor.locations = true
+	node.Accept(visitor)
+	return visitor.output()
+}
+
 func (v *printVisitor) output() string {
 	return v.buf
 }
@@ -44,6 +54,17 @@ func (v *printVisitor) nl() {
 	v.str("\n")
 }
 
+// loc returns the location of given node, if locations are printed
+func (v *printVisitor) loc(node Node) string {
+	if !v.locations {
+		return ""
+	}
+
+	loc := node.Location()
+
+	return fmt.Sprintf(" @%d:%d [%d:%d]", loc.Line, loc.Column, loc.Pos, loc.End)
+}
+
 func (v *printVisitor) line(val string) {
 	v.indent()
 	v.str(val)
@@ -76,7 +97,7 @@ func (v *printVisitor) VisitMustache(node *MustacheStatement) any {
 
 	node.Expression.Accept(v)
 
-	v.str(" }}")
+	v.str(" }}" + v.loc(node))
 	v.nl()
 
 	return nil
@@ -86,7 +107,7 @@ func (v *printVisitor) VisitMustache(node *MustacheStatement) any {
 func (v *printVisitor) VisitBlock(node *BlockStatement) any {
 	v.inBlock = true
 
-	v.line("BLOCK:")
+	v.line("BLOCK:" + v.loc(node))
 	v.depth++
 
 	node.Expression.Accept(v)
@@ -121,7 +142,12 @@ func (v *printVisitor) VisitBlock(node *BlockStatement) any {
 // VisitPartial implements corresponding Visitor interface method
 func (v *printVisitor) VisitPartial(node *PartialStatement) any {
 	v.indent()
-	v.str("{{> PARTIAL:")
+
+	if node.Program != nil {
+		v.str("{{#> PARTIAL:")
+	} else {
+		v.str("{{> PARTIAL:")
+	}
 
 	v.original = true
 	node.Name.Accept(v)
@@ -138,22 +164,65 @@ func (v *printVisitor) VisitPartial(node *PartialStatement) any {
 		node.Hash.Accept(v)
 	}
 
-	v.str(" }}")
+	v.str(" }}" + v.loc(node))
+	v.nl()
+
+	if node.Program != nil {
+		v.depth++
+		v.line("PROGRAM:")
+		v.depth++
+		node.Program.Accept(v)
+		v.depth -= 2
+	}
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (v *printVisitor) VisitDecorator(node *Decorator) any {
+	v.indent()
+	v.str("{{* DECORATOR:")
+
+	node.Expression.Accept(v)
+
+	v.str(" }}" + v.loc(node))
 	v.nl()
 
 	return nil
 }
 
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *printVisitor) VisitDecoratorBlock(node *DecoratorBlock) any {
+	v.inBlock = true
+
+	v.line("DECORATOR BLOCK:" + v.loc(node))
+	v.depth++
+
+	node.Expression.Accept(v)
+
+	if node.Program != nil {
+		v.line("PROGRAM:")
+		v.depth++
+		node.Program.Accept(v)
+		v.depth--
+	}
+
+	v.depth--
+	v.inBlock = false
+
+	return nil
+}
+
 // VisitContent implements corresponding Visitor interface method
 func (v *printVisitor) VisitContent(node *ContentStatement) any {
-	v.line("CONTENT[ '" + node.Value + "' ]")
+	v.line("CONTENT[ '" + node.Value + "' ]" + v.loc(node))
 
 	return nil
 }
 
 // VisitComment implements corresponding Visitor interface method
 func (v *printVisitor) VisitComment(node *CommentStatement) any {
-	v.line("{{! '" + node.Value + "' }}")
+	v.line("{{! '" + node.Value + "' }}" + v.loc(node))
 
 	return nil
 }
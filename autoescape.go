@@ -0,0 +1,1216 @@
+package raymond
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yoinkai/raymond/v2/ast"
+)
+
+//
+// Contextual HTML escaping
+//
+// When a template is parsed with ParseHTML(), the HTML parser state is tracked across content statements, and each
+// mustache gets an escaper chosen for the HTML context it appears in.
+//
+// The logic is a simplified version of:
+//
+//	https://github.com/golang/go/tree/master/src/html/template
+//
+
+// filterFailsafe is output instead of a value that is unsafe in its context
+const filterFailsafe = "ZgotmplZ"
+
+// htmlState represents the state of the HTML parser at a given position of a template
+type htmlState uint8
+
+const (
+	stateText        htmlState = iota // element content
+	stateRCDATA                       // <textarea> or <title> element content
+	stateTagName                      // inside a tag name
+	stateTag                          // inside a tag, between attributes
+	stateAttrName                     // inside an attribute name
+	stateAfterName                    // after an attribute name, before an equal sign
+	stateBeforeValue                  // after an equal sign, before an attribute value
+	stateAttr                         // inside a plain attribute value
+	stateURL                          // inside an URL attribute value
+	stateJS                           // inside JS code
+	stateJSDqStr                      // inside a JS double quoted string
+	stateJSSqStr                      // inside a JS single quoted string
+	stateJSTmplLit                    // inside a JS template literal
+	stateJSRegexp                     // inside a JS regular expression literal
+	stateJSBlockCmt                   // inside a JS /* block comment */
+	stateJSLineCmt                    // inside a JS // line comment
+	stateCSS                          // inside CSS code
+	stateCSSDqStr                     // inside a CSS double quoted string
+	stateCSSSqStr                     // inside a CSS single quoted string
+	stateCSSBlockCmt                  // inside a CSS /* block comment */
+	stateCSSLineCmt                   // inside a CSS // line comment
+	stateHTMLCmt                      // inside an HTML <!-- comment -->
+)
+
+var htmlStateName = map[htmlState]string{
+	stateText:        "element text",
+	stateRCDATA:      "RCDATA element text",
+	stateTagName:     "tag name",
+	stateTag:         "tag",
+	stateAttrName:    "attribute name",
+	stateAfterName:   "after attribute name",
+	stateBeforeValue: "before attribute value",
+	stateAttr:        "attribute value",
+	stateURL:         "URL",
+	stateJS:          "JS",
+	stateJSDqStr:     "JS double quoted string",
+	stateJSSqStr:     "JS single quoted string",
+	stateJSTmplLit:   "JS template literal",
+	stateJSRegexp:    "JS regular expression",
+	stateJSBlockCmt:  "JS block comment",
+	stateJSLineCmt:   "JS line comment",
+	stateCSS:         "CSS",
+	stateCSSDqStr:    "CSS double quoted string",
+	stateCSSSqStr:    "CSS single quoted string",
+	stateCSSBlockCmt: "CSS block comment",
+	stateCSSLineCmt:  "CSS line comment",
+	stateHTMLCmt:     "HTML comment",
+}
+
+// attrDelim represents the delimiter of an attribute value
+type attrDelim uint8
+
+const (
+	delimNone attrDelim = iota
+	delimDoubleQuote
+	delimSingleQuote
+	delimSpace // unquoted value
+)
+
+// attrType represents the type of content of an attribute value
+type attrType uint8
+
+const (
+	attrNone attrType = iota
+	attrURL
+	attrJS
+	attrCSS
+	attrHTML // unsafe to fill with a dynamic value
+)
+
+// urlPart represents the part of an URL being parsed
+type urlPart uint8
+
+const (
+	urlPartNone        urlPart = iota // start of URL
+	urlPartPreQuery                   // after start of URL, before query
+	urlPartQueryOrFrag                // in query or fragment
+)
+
+// jsCtx tells if a slash in JS code starts a regular expression or a division operator
+type jsCtx uint8
+
+const (
+	jsCtxRegexp jsCtx = iota
+	jsCtxDivOp
+)
+
+// element represents an HTML element whose content is not plain HTML
+type element uint8
+
+const (
+	elementNone element = iota
+	elementScript
+	elementStyle
+	elementTextarea
+	elementTitle
+)
+
+var elementName = map[element]string{
+	elementScript:   "script",
+	elementStyle:    "style",
+	elementTextarea: "textarea",
+	elementTitle:    "title",
+}
+
+// htmlContext represents the HTML parser state at a given position of a template
+type htmlContext struct {
+	state   htmlState
+	delim   attrDelim
+	attr    attrType
+	urlPart urlPart
+	jsCtx   jsCtx
+	element element
+
+	name    string // tag or attribute name being parsed
+	endTag  bool   // parsing an end tag
+	dynName bool   // attribute name was output by a mustache
+
+	// jsBraces is the stack of JS braces opened inside template literal substitutions: '$' for a `${`
+	// substitution and '{' for a plain brace. It is empty outside template literal substitutions.
+	jsBraces string
+}
+
+// String returns a string representation of receiver that can be used in error messages.
+func (c htmlContext) String() string {
+	result := htmlStateName[c.state]
+
+	switch c.delim {
+	case delimDoubleQuote:
+		result += " in double quoted attribute"
+	case delimSingleQuote:
+		result += " in single quoted attribute"
+	case delimSpace:
+		result += " in unquoted attribute"
+	}
+
+	if c.element != elementNone {
+		result += " in <" + elementName[c.element] + ">"
+	}
+
+	return result
+}
+
+// ctxEscaper escapes a mustache value for a specific HTML context
+type ctxEscaper func(value any) string
+
+// htmlEscapers stores the escaper of each mustache of a template
+type htmlEscapers map[*ast.MustacheStatement]ctxEscaper
+
+// escape escapes the value of given mustache
+func (e htmlEscapers) escape(node *ast.MustacheStatement, value any) string {
+	if escaper := e[node]; escaper != nil {
+		return escaper(value)
+	}
+
+	return escapeHTMLValue(value)
+}
+
+//
+// Template analysis
+//
+
+// contextVisitor walks an AST to compute the escaper of each mustache, according to HTML context
+type contextVisitor struct {
+	tpl      *Template
+	ctx      htmlContext
+	escapers htmlEscapers
+}
+
+// computeHTMLEscapers computes the escapers of all mustaches of given program, and returns an error if the program
+// is ambiguous or does not end in an element text context.
+func computeHTMLEscapers(tpl *Template, program *ast.Program) (result htmlEscapers, err error) {
+	defer errRecover(&err)
+
+	v := &contextVisitor{
+		tpl:      tpl,
+		escapers: make(htmlEscapers),
+	}
+
+	program.Accept(v)
+
+	if v.ctx.state != stateText {
+		v.errorf(program, "template ends in a non-text context: %s", v.ctx)
+	}
+
+	return v.escapers, nil
+}
+
+// errorf panics with a contextual escaping error located at given node
+func (v *contextVisitor) errorf(node ast.Node, format string, args ...any) {
+	panic(v.tpl.newParseError(node.Location().Pos, "contextual escaping error: "+fmt.Sprintf(format, args...)))
+}
+
+// VisitProgram implements corresponding Visitor interface method
+func (v *contextVisitor) VisitProgram(node *ast.Program) any {
+	for _, n := range node.Body {
+		n.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitMustache implements corresponding Visitor interface method
+func (v *contextVisitor) VisitMustache(node *ast.MustacheStatement) any {
+	escaper, ctx, err := v.ctx.escaper()
+	if err != nil {
+		v.errorf(node, "%s", err)
+	}
+
+	v.escapers[node] = escaper
+	v.ctx = ctx
+
+	return nil
+}
+
+// VisitBlock implements corresponding Visitor interface method
+//
+// As a block may be evaluated any number of times, all its programs must end in the context they started in.
+func (v *contextVisitor) VisitBlock(node *ast.BlockStatement) any {
+	start := v.ctx
+
+	for _, program := range []*ast.Program{node.Program, node.Inverse} {
+		if program == nil {
+			continue
+		}
+
+		program.Accept(v)
+
+		if v.ctx != start {
+			v.errorf(node, "{{#%s}} block is ambiguous, it starts in context %s and ends in context %s", node.Expression.Canonical(), start, v.ctx)
+		}
+	}
+
+	return nil
+}
+
+// VisitPartial implements corresponding Visitor interface method
+func (v *contextVisitor) VisitPartial(node *ast.PartialStatement) any {
+	if v.ctx.state != stateText {
+		v.errorf(node, "partial called in a non-text context: %s", v.ctx)
+	}
+
+	// partial block content is rendered by the partial, so it must stay in text context
+	if node.Program != nil {
+		start := v.ctx
+
+		node.Program.Accept(v)
+
+		if v.ctx != start {
+			name, _ := ast.HelperNameStr(node.Name)
+			v.errorf(node, "{{#> %s}} partial block is ambiguous, it starts in context %s and ends in context %s", name, start, v.ctx)
+		}
+	}
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (v *contextVisitor) VisitDecorator(node *ast.Decorator) any { return nil }
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+//
+// An inline partial is analyzed when it is called, like other partials. The content of other decorator blocks is not
+// output to the template, so its mustaches are escaped as HTML text.
+func (v *contextVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) any { return nil }
+
+// VisitContent implements corresponding Visitor interface method
+func (v *contextVisitor) VisitContent(node *ast.ContentStatement) any {
+	ctx, err := v.ctx.advance(node.Value)
+	if err != nil {
+		v.errorf(node, "%s", err)
+	}
+
+	v.ctx = ctx
+
+	return nil
+}
+
+// VisitComment implements corresponding Visitor interface method
+func (v *contextVisitor) VisitComment(node *ast.CommentStatement) any { return nil }
+
+// VisitExpression implements corresponding Visitor interface method
+func (v *contextVisitor) VisitExpression(node *ast.Expression) any { return nil }
+
+// VisitSubExpression implements corresponding Visitor interface method
+func (v *contextVisitor) VisitSubExpression(node *ast.SubExpression) any { return nil }
+
+// VisitPath implements corresponding Visitor interface method
+func (v *contextVisitor) VisitPath(node *ast.PathExpression) any { return nil }
+
+// VisitString implements corresponding Visitor interface method
+func (v *contextVisitor) VisitString(node *ast.StringLiteral) any { return nil }
+
+// VisitBoolean implements corresponding Visitor interface method
+func (v *contextVisitor) VisitBoolean(node *ast.BooleanLiteral) any { return nil }
+
+// VisitNumber implements corresponding Visitor interface method
+func (v *contextVisitor) VisitNumber(node *ast.NumberLiteral) any { return nil }
+
+// VisitHash implements corresponding Visitor interface method
+func (v *contextVisitor) VisitHash(node *ast.Hash) any { return nil }
+
+// VisitHashPair implements corresponding Visitor interface method
+func (v *contextVisitor) VisitHashPair(node *ast.HashPair) any { return nil }
+
+//
+// Mustaches
+//
+
+// escaper returns the escaper for a mustache in receiver context, and the context after that mustache
+func (c htmlContext) escaper() (ctxEscaper, htmlContext, error) {
+	var result ctxEscaper
+
+	switch c.state {
+	case stateText, stateRCDATA:
+		return escapeHTMLValue, c, nil
+	case stateTagName:
+		return nil, c, fmt.Errorf("mustache in a tag name")
+	case stateAttrName:
+		if c.dynName || c.name != "" {
+			return nil, c, fmt.Errorf("mustache in the middle of an attribute name")
+		}
+
+		return filterAttrName, htmlContext{state: stateAttrName, element: c.element, dynName: true}, nil
+	case stateTag, stateAfterName:
+		return filterAttrName, htmlContext{state: stateAttrName, element: c.element, dynName: true}, nil
+	case stateBeforeValue:
+		if c.attr == attrHTML {
+			return nil, c, fmt.Errorf("mustache in an attribute containing HTML")
+		}
+
+		// unquoted attribute value
+		return valueContext(c.element, c.attr, delimSpace).escaper()
+	case stateHTMLCmt, stateJSBlockCmt, stateJSLineCmt, stateCSSBlockCmt, stateCSSLineCmt:
+		return elide, c, nil
+	case stateAttr:
+		if c.attr == attrHTML {
+			return nil, c, fmt.Errorf("mustache in an attribute containing HTML")
+		}
+
+		result = escapeStrValue(NoEscape)
+	case stateURL:
+		switch c.urlPart {
+		case urlPartNone:
+			result = escapeStrValue(filterAndNormalizeURL)
+			c.urlPart = urlPartPreQuery
+		case urlPartPreQuery:
+			result = escapeStrValue(normalizeURL)
+		default:
+			result = escapeStrValue(escapeURLComponent)
+		}
+	case stateJS:
+		result = escapeJSValue
+		c.jsCtx = jsCtxDivOp
+	case stateJSDqStr, stateJSSqStr, stateJSTmplLit:
+		result = escapeStrValue(escapeJSString)
+	case stateJSRegexp:
+		result = escapeStrValue(escapeJSRegexp)
+	case stateCSS:
+		result = escapeStrValue(filterCSSValue)
+	case stateCSSDqStr, stateCSSSqStr:
+		result = escapeStrValue(escapeCSSString)
+	default:
+		return nil, c, fmt.Errorf("unexpected context: %s", c)
+	}
+
+	switch c.delim {
+	case delimDoubleQuote, delimSingleQuote:
+		result = chainEscaper(result, EscapeHTML)
+	case delimSpace:
+		result = chainEscaper(result, escapeUnquotedAttr)
+	}
+
+	return result, c, nil
+}
+
+//
+// Transitions
+//
+
+// advance returns the context after given content in receiver context
+func (c htmlContext) advance(s string) (htmlContext, error) {
+	for len(s) > 0 {
+		var n int
+		var err error
+
+		switch {
+		case c.delim != delimNone:
+			c, n = c.advanceAttrValue(s)
+		case c.element != elementNone && c.isElementContent():
+			c, n = c.advanceElementContent(s)
+		default:
+			c, n, err = c.transition(s)
+		}
+
+		if err != nil {
+			return c, err
+		}
+
+		s = s[n:]
+	}
+
+	return c, nil
+}
+
+// isElementContent returns true if receiver is inside the content of a special element
+func (c htmlContext) isElementContent() bool {
+	switch c.state {
+	case stateTagName, stateTag, stateAttrName, stateAfterName, stateBeforeValue:
+		return false
+	}
+
+	return true
+}
+
+// advanceAttrValue scans an attribute value, until its delimiter
+func (c htmlContext) advanceAttrValue(s string) (htmlContext, int) {
+	var i int
+
+	switch c.delim {
+	case delimDoubleQuote:
+		i = strings.IndexByte(s, '"')
+	case delimSingleQuote:
+		i = strings.IndexByte(s, '\'')
+	default:
+		i = strings.IndexAny(s, " \t\n\f\r>")
+	}
+
+	if i == -1 {
+		return c.advanceSub(s), len(s)
+	}
+
+	c = htmlContext{state: stateTag, element: c.element}
+
+	if s[i] == '"' || s[i] == '\'' {
+		// skip delimiter
+		return c, i + 1
+	}
+
+	return c, i
+}
+
+// advanceElementContent scans the content of a special element, until its end tag
+func (c htmlContext) advanceElementContent(s string) (htmlContext, int) {
+	endTag := "</" + elementName[c.element]
+
+	i := strings.Index(strings.ToLower(s), endTag)
+	if i == -1 {
+		return c.advanceSub(s), len(s)
+	}
+
+	// scan end tag name
+	return htmlContext{state: stateTagName, endTag: true, name: elementName[c.element]}, i + len(endTag)
+}
+
+// advanceSub scans given content that is entirely part of an attribute value or an element content
+func (c htmlContext) advanceSub(s string) htmlContext {
+	for len(s) > 0 {
+		var n int
+
+		// those transitions never fail
+		c, n, _ = c.transition(s)
+
+		s = s[n:]
+	}
+
+	return c
+}
+
+// transition scans the beginning of given content and returns new context and number of bytes consumed
+func (c htmlContext) transition(s string) (htmlContext, int, error) {
+	switch c.state {
+	case stateText:
+		return c.tText(s)
+	case stateTagName:
+		return c.tTagName(s)
+	case stateTag:
+		return c.tTag(s)
+	case stateAttrName:
+		return c.tAttrName(s)
+	case stateAfterName:
+		return c.tAfterName(s)
+	case stateBeforeValue:
+		return c.tBeforeValue(s)
+	case stateHTMLCmt:
+		return c.tHTMLCmt(s)
+	case stateURL:
+		return c.tURL(s)
+	case stateJS:
+		return c.tJS(s)
+	case stateJSDqStr, stateJSSqStr, stateJSTmplLit, stateJSRegexp:
+		return c.tJSDelimited(s)
+	case stateJSBlockCmt, stateCSSBlockCmt:
+		return c.tBlockCmt(s)
+	case stateJSLineCmt, stateCSSLineCmt:
+		return c.tLineCmt(s)
+	case stateCSS:
+		return c.tCSS(s)
+	case stateCSSDqStr, stateCSSSqStr:
+		return c.tCSSStr(s)
+	}
+
+	// stateRCDATA, stateAttr
+	return c, len(s), nil
+}
+
+// tText scans element text
+func (c htmlContext) tText(s string) (htmlContext, int, error) {
+	i := strings.IndexByte(s, '<')
+	if i == -1 {
+		return c, len(s), nil
+	}
+
+	rest := s[i+1:]
+	if strings.HasPrefix(rest, "!--") {
+		return htmlContext{state: stateHTMLCmt}, i + 4, nil
+	}
+
+	n := i + 1
+
+	endTag := strings.HasPrefix(rest, "/")
+	if endTag {
+		rest = rest[1:]
+		n++
+	}
+
+	if (rest != "") && !isASCIILetter(rest[0]) {
+		// not a tag
+		return c, n, nil
+	}
+
+	return htmlContext{state: stateTagName, endTag: endTag}, n, nil
+}
+
+// tTagName scans a tag name
+func (c htmlContext) tTagName(s string) (htmlContext, int, error) {
+	i := 0
+	for (i < len(s)) && isTagNameChar(s[i]) {
+		i++
+	}
+
+	c.name += s[:i]
+	if i == len(s) {
+		return c, i, nil
+	}
+
+	result := htmlContext{state: stateTag}
+
+	if !c.endTag {
+		name := strings.ToLower(c.name)
+		for el, elName := range elementName {
+			if elName == name {
+				result.element = el
+			}
+		}
+	}
+
+	return result, i, nil
+}
+
+// tTag scans inside a tag, between attributes
+func (c htmlContext) tTag(s string) (htmlContext, int, error) {
+	i := skipSpaces(s, "/")
+	if i == len(s) {
+		return c, i, nil
+	}
+
+	if s[i] == '>' {
+		// end of tag
+		switch c.element {
+		case elementScript:
+			return htmlContext{state: stateJS, element: c.element}, i + 1, nil
+		case elementStyle:
+			return htmlContext{state: stateCSS, element: c.element}, i + 1, nil
+		case elementTextarea, elementTitle:
+			return htmlContext{state: stateRCDATA, element: c.element}, i + 1, nil
+		}
+
+		return htmlContext{state: stateText}, i + 1, nil
+	}
+
+	return htmlContext{state: stateAttrName, element: c.element}, i, nil
+}
+
+// tAttrName scans an attribute name
+func (c htmlContext) tAttrName(s string) (htmlContext, int, error) {
+	i := 0
+	for (i < len(s)) && isAttrNameChar(s[i]) {
+		i++
+	}
+
+	if c.dynName && (i > 0) {
+		return c, 0, fmt.Errorf("attribute name partially output by a mustache")
+	}
+
+	c.name += s[:i]
+	if i == len(s) {
+		return c, i, nil
+	}
+
+	return htmlContext{state: stateAfterName, element: c.element, attr: attrTypeOf(c.name)}, i, nil
+}
+
+// tAfterName scans after an attribute name
+func (c htmlContext) tAfterName(s string) (htmlContext, int, error) {
+	i := skipSpaces(s, "")
+	if i == len(s) {
+		return c, i, nil
+	}
+
+	if s[i] == '=' {
+		c.state = stateBeforeValue
+		return c, i + 1, nil
+	}
+
+	// attribute without value
+	return htmlContext{state: stateTag, element: c.element}, i, nil
+}
+
+// tBeforeValue scans before an attribute value
+func (c htmlContext) tBeforeValue(s string) (htmlContext, int, error) {
+	i := skipSpaces(s, "")
+	if i == len(s) {
+		return c, i, nil
+	}
+
+	switch s[i] {
+	case '"':
+		return valueContext(c.element, c.attr, delimDoubleQuote), i + 1, nil
+	case '\'':
+		return valueContext(c.element, c.attr, delimSingleQuote), i + 1, nil
+	case '>':
+		// empty value
+		return htmlContext{state: stateTag, element: c.element}, i, nil
+	}
+
+	return valueContext(c.element, c.attr, delimSpace), i, nil
+}
+
+// tHTMLCmt scans an HTML comment
+func (c htmlContext) tHTMLCmt(s string) (htmlContext, int, error) {
+	i := strings.Index(s, "-->")
+	if i == -1 {
+		return c, len(s), nil
+	}
+
+	return htmlContext{state: stateText}, i + 3, nil
+}
+
+// tURL scans an URL
+func (c htmlContext) tURL(s string) (htmlContext, int, error) {
+	if strings.ContainsAny(s, "?#") {
+		c.urlPart = urlPartQueryOrFrag
+	} else if c.urlPart == urlPartNone {
+		c.urlPart = urlPartPreQuery
+	}
+
+	return c, len(s), nil
+}
+
+// tJS scans JS code
+func (c htmlContext) tJS(s string) (htmlContext, int, error) {
+	specials := "\"'`/"
+	if c.jsBraces != "" {
+		// inside a template literal substitution
+		specials += "{}"
+	}
+
+	i := strings.IndexAny(s, specials)
+	if i == -1 {
+		c.jsCtx = nextJSCtx(s, c.jsCtx)
+		return c, len(s), nil
+	}
+
+	c.jsCtx = nextJSCtx(s[:i], c.jsCtx)
+
+	switch s[i] {
+	case '"':
+		c.state = stateJSDqStr
+	case '\'':
+		c.state = stateJSSqStr
+	case '`':
+		c.state = stateJSTmplLit
+	case '{':
+		c.jsBraces += "{"
+		c.jsCtx = jsCtxRegexp
+	case '}':
+		last := len(c.jsBraces) - 1
+		if c.jsBraces[last] == '$' {
+			// end of template literal substitution
+			c.state = stateJSTmplLit
+		}
+
+		c.jsBraces = c.jsBraces[:last]
+		c.jsCtx = jsCtxRegexp
+	case '/':
+		switch {
+		case strings.HasPrefix(s[i+1:], "/"):
+			c.state = stateJSLineCmt
+			i++
+		case strings.HasPrefix(s[i+1:], "*"):
+			c.state = stateJSBlockCmt
+			i++
+		case c.jsCtx == jsCtxRegexp:
+			c.state = stateJSRegexp
+		default:
+			// division operator
+			c.jsCtx = jsCtxRegexp
+		}
+	}
+
+	return c, i + 1, nil
+}
+
+// tJSDelimited scans a JS string, template literal or regular expression
+func (c htmlContext) tJSDelimited(s string) (htmlContext, int, error) {
+	specials := `\"`
+	switch c.state {
+	case stateJSSqStr:
+		specials = `\'`
+	case stateJSTmplLit:
+		specials = "\\`$"
+	case stateJSRegexp:
+		specials = `\/[]`
+	}
+
+	inCharset := false
+
+	for i := 0; i < len(s); i++ {
+		j := strings.IndexAny(s[i:], specials)
+		if j == -1 {
+			break
+		}
+
+		i += j
+
+		switch s[i] {
+		case '\\':
+			// skip escaped character
+			i++
+		case '[':
+			inCharset = true
+		case ']':
+			inCharset = false
+		case '$':
+			if strings.HasPrefix(s[i+1:], "{") {
+				// start of template literal substitution
+				c.state = stateJS
+				c.jsCtx = jsCtxRegexp
+				c.jsBraces += "$"
+
+				return c, i + 2, nil
+			}
+		default:
+			if !inCharset {
+				// end delimiter
+				c.state = stateJS
+				c.jsCtx = jsCtxDivOp
+
+				return c, i + 1, nil
+			}
+		}
+	}
+
+	return c, len(s), nil
+}
+
+// tBlockCmt scans a JS or CSS block comment
+func (c htmlContext) tBlockCmt(s string) (htmlContext, int, error) {
+	i := strings.Index(s, "*/")
+	if i == -1 {
+		return c, len(s), nil
+	}
+
+	if c.state == stateJSBlockCmt {
+		c.state = stateJS
+	} else {
+		c.state = stateCSS
+	}
+
+	return c, i + 2, nil
+}
+
+// tLineCmt scans a JS or CSS line comment
+func (c htmlContext) tLineCmt(s string) (htmlContext, int, error) {
+	i := strings.IndexByte(s, '\n')
+	if i == -1 {
+		return c, len(s), nil
+	}
+
+	if c.state == stateJSLineCmt {
+		c.state = stateJS
+	} else {
+		c.state = stateCSS
+	}
+
+	return c, i + 1, nil
+}
+
+// tCSS scans CSS code
+func (c htmlContext) tCSS(s string) (htmlContext, int, error) {
+	i := strings.IndexAny(s, "\"'/")
+	if i == -1 {
+		return c, len(s), nil
+	}
+
+	switch s[i] {
+	case '"':
+		c.state = stateCSSDqStr
+	case '\'':
+		c.state = stateCSSSqStr
+	case '/':
+		switch {
+		case strings.HasPrefix(s[i+1:], "*"):
+			c.state = stateCSSBlockCmt
+			i++
+		case strings.HasPrefix(s[i+1:], "/"):
+			c.state = stateCSSLineCmt
+			i++
+		}
+	}
+
+	return c, i + 1, nil
+}
+
+// tCSSStr scans a CSS string
+func (c htmlContext) tCSSStr(s string) (htmlContext, int, error) {
+	specials := `\"`
+	if c.state == stateCSSSqStr {
+		specials = `\'`
+	}
+
+	for i := 0; i < len(s); i++ {
+		j := strings.IndexAny(s[i:], specials)
+		if j == -1 {
+			break
+		}
+
+		i += j
+
+		if s[i] != '\\' {
+			c.state = stateCSS
+			return c, i + 1, nil
+		}
+
+		// skip escaped character
+		i++
+	}
+
+	return c, len(s), nil
+}
+
+// valueContext returns the context at the beginning of an attribute value
+func valueContext(el element, attr attrType, delim attrDelim) htmlContext {
+	result := htmlContext{state: stateAttr, element: el, attr: attr, delim: delim}
+
+	switch attr {
+	case attrURL:
+		result.state = stateURL
+	case attrJS:
+		result.state = stateJS
+	case attrCSS:
+		result.state = stateCSS
+	}
+
+	return result
+}
+
+// jsRegexpKeywords are the keywords after which a slash starts a regular expression
+var jsRegexpKeywords = map[string]bool{
+	"case":       true,
+	"delete":     true,
+	"do":         true,
+	"else":       true,
+	"in":         true,
+	"instanceof": true,
+	"new":        true,
+	"return":     true,
+	"throw":      true,
+	"typeof":     true,
+	"void":       true,
+}
+
+// nextJSCtx returns the context that determines whether a slash after given JS code starts a regular expression or a
+// division operator
+func nextJSCtx(s string, prev jsCtx) jsCtx {
+	s = strings.TrimRight(s, " \t\n\f\r")
+	if s == "" {
+		return prev
+	}
+
+	c := s[len(s)-1]
+	switch {
+	case c == ')' || c == ']':
+		return jsCtxDivOp
+	case isJSIdentChar(c):
+		i := len(s)
+		for (i > 0) && isJSIdentChar(s[i-1]) {
+			i--
+		}
+
+		if jsRegexpKeywords[s[i:]] {
+			return jsCtxRegexp
+		}
+
+		return jsCtxDivOp
+	}
+
+	return jsCtxRegexp
+}
+
+// urlAttrs are the attributes whose value is an URL
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"archive":    true,
+	"background": true,
+	"cite":       true,
+	"classid":    true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"profile":    true,
+	"src":        true,
+	"srcset":     true,
+	"usemap":     true,
+	"xmlns":      true,
+}
+
+// attrTypeOf returns the type of content of given attribute
+func attrTypeOf(name string) attrType {
+	name = strings.ToLower(name)
+
+	if strings.HasPrefix(name, "data-") {
+		name = name[len("data-"):]
+	} else if i := strings.IndexByte(name, ':'); i != -1 {
+		if name[:i] == "xmlns" {
+			return attrURL
+		}
+
+		// namespaced attribute
+		name = name[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case name == "srcdoc":
+		return attrHTML
+	case urlAttrs[name], strings.Contains(name, "src"), strings.Contains(name, "uri"), strings.Contains(name, "url"):
+		return attrURL
+	}
+
+	return attrNone
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isTagNameChar(c byte) bool {
+	return isASCIILetter(c) || ('0' <= c && c <= '9') || c == '-' || c == ':'
+}
+
+func isAttrNameChar(c byte) bool {
+	return !strings.ContainsRune(" \t\n\f\r/=>\"'", rune(c))
+}
+
+func isJSIdentChar(c byte) bool {
+	return isASCIILetter(c) || ('0' <= c && c <= '9') || c == '_' || c == '$'
+}
+
+// skipSpaces returns the index of the first character of given string that is neither a whitespace nor in given extra characters
+func skipSpaces(s string, extra string) int {
+	for i := 0; i < len(s); i++ {
+		if !strings.ContainsRune(" \t\n\f\r"+extra, rune(s[i])) {
+			return i
+		}
+	}
+
+	return len(s)
+}
+
+//
+// Escapers
+//
+
+// escapeStrValue returns an escaper that applies given string escaper to the string representation of a value
+func escapeStrValue(escaper Escaper) ctxEscaper {
+	return func(value any) string {
+		return escaper(Str(value))
+	}
+}
+
+// chainEscaper returns an escaper that applies given string escaper after given escaper
+func chainEscaper(escaper ctxEscaper, next Escaper) ctxEscaper {
+	return func(value any) string {
+		return next(escaper(value))
+	}
+}
+
+// escapeHTMLValue escapes a value in element text context
+func escapeHTMLValue(value any) string {
+	return EscapeHTML(Str(value))
+}
+
+// elide outputs nothing, it is used in comments
+func elide(value any) string {
+	return ""
+}
+
+// filterAttrName outputs given attribute name only if it is a plain attribute
+func filterAttrName(value any) string {
+	s := Str(value)
+	if s == "" {
+		return s
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isTagNameChar(s[i]) && (s[i] != '_') {
+			return filterFailsafe
+		}
+	}
+
+	if attrTypeOf(s) != attrNone {
+		return filterFailsafe
+	}
+
+	return s
+}
+
+// escapeUnquotedAttr escapes an unquoted attribute value
+func escapeUnquotedAttr(s string) string {
+	if s == "" {
+		// an empty value would make next attribute the value of current one
+		return filterFailsafe
+	}
+
+	var b strings.Builder
+
+	for _, r := range EscapeHTML(s) {
+		switch r {
+		case ' ', '\t', '\n', '\f', '\r', 0:
+			b.WriteString("&#" + strconv.Itoa(int(r)) + ";")
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// safeURLSchemes are the URL schemes allowed at the beginning of an URL attribute value
+var safeURLSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// filterAndNormalizeURL rejects URLs with unsafe schemes, like `javascript:`, and normalizes the others
+func filterAndNormalizeURL(s string) string {
+	if i := strings.IndexAny(s, ":/?#"); (i != -1) && (s[i] == ':') {
+		if !safeURLSchemes[strings.ToLower(s[:i])] {
+			return "#" + filterFailsafe
+		}
+	}
+
+	return normalizeURL(s)
+}
+
+// normalizeURL percent-encodes the characters that are not allowed in an URL
+func normalizeURL(s string) string {
+	return percentEncode(s, "-._~:/?#[]@!$&'()*+,;=%")
+}
+
+// escapeURLComponent percent-encodes all reserved characters, so that given string can be used in an URL query or fragment
+func escapeURLComponent(s string) string {
+	return percentEncode(s, "-._~")
+}
+
+// percentEncode percent-encodes all bytes of given string except ASCII letters, digits and given allowed characters
+func percentEncode(s string, allowed string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isASCIILetter(c) || ('0' <= c && c <= '9') || strings.IndexByte(allowed, c) != -1 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// escapeJSValue outputs given value as a JS value
+func escapeJSValue(value any) string {
+	// spaces prevent the value from running into adjacent tokens
+	switch val := value.(type) {
+	case nil:
+		return " null "
+	case bool:
+		return " " + strconv.FormatBool(val) + " "
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return " " + Str(val) + " "
+	}
+
+	return ` "` + escapeJSString(Str(value)) + `" `
+}
+
+// escapeJSString escapes given string so that it can be inserted in a JS string or template literal
+func escapeJSString(s string) string {
+	return escapeJS(s, false)
+}
+
+// escapeJSRegexp escapes given string so that it can be inserted in a JS regular expression literal
+func escapeJSRegexp(s string) string {
+	return escapeJS(s, true)
+}
+
+// escapeJS escapes given string to be inserted in JS code
+func escapeJS(s string, regexp bool) string {
+	var b strings.Builder
+
+	for _, r := range s {
+		switch {
+		case regexp && strings.ContainsRune(`.+*?()[]{}|^$/-`, r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case (r < 0x20) || strings.ContainsRune("\"'`<>&=$", r) || (r == '\u2028') || (r == '\u2029'):
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// filterCSSValue outputs given CSS value only if it contains safe characters
+func filterCSSValue(s string) string {
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "expression") || strings.Contains(lower, "mozbinding") {
+		return filterFailsafe
+	}
+
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" #%,.-_!+", r) {
+			return filterFailsafe
+		}
+	}
+
+	return s
+}
+
+// escapeCSSString escapes given string so that it can be inserted in a CSS string
+func escapeCSSString(s string) string {
+	var b strings.Builder
+
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(" #%,.-_", r) {
+			b.WriteRune(r)
+		} else if r == utf8.RuneError {
+			b.WriteString(`\FFFD `)
+		} else {
+			fmt.Fprintf(&b, `\%X `, r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package raymond
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var autoescapeTests = []struct {
+	name   string
+	input  string
+	data   any
+	output string
+}{
+	{"text", `<p>{{a}}</p>`, map[string]any{"a": `<b a="x">`}, `<p>&lt;b a&#x3D;&quot;x&quot;&gt;</p>`},
+	{"unescaped", `<p>{{{a}}}</p>`, map[string]any{"a": `<b>`}, `<p><b></p>`},
+	{"safe string", `<p>{{a}}</p>`, map[string]any{"a": SafeString(`<b>`)}, `<p><b></p>`},
+	{"rcdata", `<title>{{a}}</title>`, map[string]any{"a": `</title>`}, `<title>&lt;/title&gt;</title>`},
+	{"html comment", `<!-- {{a}} -->{{a}}`, map[string]any{"a": "x"}, `<!--  -->x`},
+	{"quoted attribute", `<div class="{{a}}">`, map[string]any{"a": `x" onclick="y`}, `<div class="x&quot; onclick&#x3D;&quot;y">`},
+	{"single quoted attribute", `<div class='{{a}}'>`, map[string]any{"a": `x' y`}, `<div class='x&#x27; y'>`},
+	{"unquoted attribute", `<div class={{a}}>`, map[string]any{"a": "x onclick=y"}, `<div class=x&#32;onclick&#x3D;y>`},
+	{"empty unquoted attribute", `<input value={{a}} disabled>`, map[string]any{"a": ""}, `<input value=ZgotmplZ disabled>`},
+	{"attribute name", `<input {{a}} {{b}}>`, map[string]any{"a": "checked", "b": "onclick"}, `<input checked ZgotmplZ>`},
+	{"url", `<a href="{{a}}">`, map[string]any{"a": "http://example.com/a b?c"}, `<a href="http://example.com/a%20b?c">`},
+	{"url scheme", `<a href="{{a}}">`, map[string]any{"a": "javascript:alert(1)"}, `<a href="#ZgotmplZ">`},
+	{"url path", `<a href="/users/{{a}}">`, map[string]any{"a": "javascript:alert(1)"}, `<a href="/users/javascript:alert(1)">`},
+	{"url query", `<a href="/search?q={{a}}&amp;p={{b}}">`, map[string]any{"a": "a&b=c d", "b": 2}, `<a href="/search?q=a%26b%3Dc%20d&amp;p=2">`},
+	{"url unquoted", `<img src={{a}}>`, map[string]any{"a": "/img.png"}, `<img src=/img.png>`},
+	{"script value", `<script>var x = {{a}}, y = {{b}}, z = {{c}};</script>`, map[string]any{"a": "</script>", "b": 42, "c": true}, `<script>var x =  "\u003C/script\u003E" , y =  42 , z =  true ;</script>`},
+	{"script string", `<script>var x = "{{a}}" + '{{a}}';</script>`, map[string]any{"a": `"'\`}, `<script>var x = "\u0022\u0027\\" + '\u0022\u0027\\';</script>`},
+	{"script regexp", `<script>var r = /{{a}}/;</script>`, map[string]any{"a": "a.b"}, `<script>var r = /a\.b/;</script>`},
+	{"script division", `<script>var x = a / {{b}};</script>`, map[string]any{"b": 2}, `<script>var x = a /  2 ;</script>`},
+	{"script template literal", "<script>var s = `a {{a}}`;</script>", map[string]any{"a": "`${alert(1)}"}, "<script>var s = `a \\u0060\\u0024{alert(1)}`;</script>"},
+	{"script template literal substitution", "<script>var s = `${ {{a}} }`;</script>", map[string]any{"a": "alert(1)"}, "<script>var s = `${  \"alert(1)\"  }`;</script>"},
+	{"script string in template literal substitution", "<script>var s = `${ \"{{a}}\" }`;</script>", map[string]any{"a": `"+alert(1)+"`}, "<script>var s = `${ \"\\u0022+alert(1)+\\u0022\" }`;</script>"},
+	{"script nested template literal substitution", "<script>var s = `${ f({a: `${ {{a}} }`}) } {{b}}`;</script>", map[string]any{"a": 1, "b": "}"}, "<script>var s = `${ f({a: `${  1  }`}) } }`;</script>"},
+	{"script comment", "<script>//{{a}}\n/*{{a}}*/{{b}}</script>", map[string]any{"a": "x", "b": 1}, "<script>//\n/**/ 1 </script>"},
+	{"event handler", `<button onclick="go({{a}})">`, map[string]any{"a": "x"}, `<button onclick="go( &quot;x&quot; )">`},
+	{"style value", `<div style="color: {{a}}; background: {{b}}">`, map[string]any{"a": "red", "b": "url(javascript:x)"}, `<div style="color: red; background: ZgotmplZ">`},
+	{"style string", `<style>p:after { content: "{{a}}"; }</style>`, map[string]any{"a": `a"b`}, `<style>p:after { content: "a\22 b"; }</style>`},
+	{"each", `<ul>{{#each items}}<li class="{{this}}">{{this}}</li>{{/each}}</ul>`, map[string]any{"items": []string{"a<", "b>"}}, `<ul><li class="a&lt;">a&lt;</li><li class="b&gt;">b&gt;</li></ul>`},
+	{"balanced if", `<div {{#if a}}class="{{a}}"{{else}}id="x"{{/if}}>`, map[string]any{"a": "y"}, `<div class="y">`},
+}
+
+func TestAutoescape(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range autoescapeTests {
+		tpl, err := ParseHTML(test.input)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected parse error: %s", test.name, err)
+			continue
+		}
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+var autoescapeErrors = []struct {
+	name  string
+	input string
+	err   string
+}{
+	{"tag name", `<{{a}}>`, "mustache in a tag name"},
+	{"partial attribute name", `<div data-{{a}}="x">`, "mustache in the middle of an attribute name"},
+	{"dynamic attribute name prefix", `<div {{a}}click="x">`, "attribute name partially output by a mustache"},
+	{"ambiguous if", `<a {{#if a}}href="{{/if}}">`, "{{#if}} block is ambiguous"},
+	{"ambiguous else", "<p>{{#if a}}x{{else}}<b {{/if}}</p>", "{{#if}} block is ambiguous"},
+	{"partial in attribute", `<div class="{{> p}}">`, "partial called in a non-text context"},
+	{"ambiguous partial block", `{{#> p}}<a href="{{/p}}">`, "{{#> p}} partial block is ambiguous"},
+	{"unterminated tag", `<div class="x`, "template ends in a non-text context"},
+	{"srcdoc", `<iframe srcdoc="{{a}}">`, "mustache in an attribute containing HTML"},
+}
+
+func TestAutoescapeErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range autoescapeErrors {
+		_, err := ParseHTML(test.input)
+		if err == nil {
+			t.Errorf("Test '%s' failed - Error expected", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test '%s' failed - Incorrect error returned\nexpected\n\t%q\ngot\n\t%q", test.name, test.err, err)
+		}
+	}
+}
+
+func TestAutoescapeErrorLocation(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseHTML("<ul>\n  <{{tag}}>")
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a ParseError, got: %v", err)
+	}
+
+	if (perr.Line != 2) || (perr.Column != 4) || (perr.Message != "contextual escaping error: mustache in a tag name") {
+		t.Errorf("Unexpected contextual escaping error: %#v", perr)
+	}
+
+	if expected := "  <{{tag}}>\n   ^"; perr.Excerpt != expected {
+		t.Errorf("Unexpected contextual escaping error excerpt\nexpected\n\t%q\ngot\n\t%q", expected, perr.Excerpt)
+	}
+}
+
+func TestAutoescapePartial(t *testing.T) {
+	t.Parallel()
+
+	partial := MustParse(`<a href="{{url}}">{{name}}</a>`)
+
+	tpl := MustParseHTML(`<p>{{> link}}</p>`)
+	tpl.RegisterPartialTemplate("link", partial)
+
+	ctx := map[string]string{"url": "javascript:alert(1)", "name": "<b>"}
+
+	if output, expected := tpl.MustExec(ctx), `<p><a href="#ZgotmplZ">&lt;b&gt;</a></p>`; output != expected {
+		t.Errorf("Unexpected output with contextual escaping: %q, expected %q", output, expected)
+	}
+
+	// partial template itself is not affected
+	if output, expected := partial.MustExec(ctx), `<a href="javascript:alert(1)">&lt;b&gt;</a>`; output != expected {
+		t.Errorf("Unexpected output without contextual escaping: %q, expected %q", output, expected)
+	}
+
+	tpl = MustParseHTML(`<p>{{> bad}}</p>`)
+	tpl.RegisterPartial("bad", `<div class="`)
+
+	if _, err := tpl.Exec(ctx); err == nil || !strings.Contains(err.Error(), "template ends in a non-text context") {
+		t.Errorf("Expected contextual escaping error for partial, got: %v", err)
+	}
+}
+
+func TestAutoescapeInlinePartial(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParseHTML(`{{#*inline "link"}}<a href="{{url}}">{{name}}</a>{{/inline}}<p>{{> link}}</p>`)
+
+	ctx := map[string]string{"url": "javascript:alert(1)", "name": "<b>"}
+
+	if output, expected := tpl.MustExec(ctx), `<p><a href="#ZgotmplZ">&lt;b&gt;</a></p>`; output != expected {
+		t.Errorf("Unexpected output with contextual escaping: %q, expected %q", output, expected)
+	}
+}
+
+func TestAutoescapePartialBlock(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParseHTML(`{{#> card}}<a href="{{url}}">{{name}}</a>{{/card}}`)
+	tpl.RegisterPartial("card", `<div onclick="show({{name}})">{{> @partial-block}}</div>`)
+
+	ctx := map[string]string{"url": "javascript:alert(1)", "name": "<b>"}
+
+	if output, expected := tpl.MustExec(ctx), `<div onclick="show( &quot;\u003Cb\u003E&quot; )"><a href="#ZgotmplZ">&lt;b&gt;</a></div>`; output != expected {
+		t.Errorf("Unexpected output with contextual escaping: %q, expected %q", output, expected)
+	}
+}
+
+func ExampleParseHTML() {
+	tpl := MustParseHTML(`<a href="{{url}}" onclick="track({{id}})">{{name}}</a>`)
+
+	result := tpl.MustExec(map[string]any{
+		"url":  "javascript:alert(1)",
+		"id":   `x"y`,
+		"name": "<b>Me</b>",
+	})
+	fmt.Print(result)
+	// Output: <a href="#ZgotmplZ" onclick="track( &quot;x\u0022y&quot; )">&lt;b&gt;Me&lt;/b&gt;</a>
+}
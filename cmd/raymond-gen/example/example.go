@@ -0,0 +1,31 @@
+// Package example shows templates generated by raymond-gen.
+package example
+
+import "strings"
+
+//go:generate go run .. -type *Post -dir templates -type comment=Comment -helper upper=Upper -o templates_gen.go templates/*.hbs
+
+// Author is the author of a post or a comment.
+type Author struct {
+	FirstName string
+	LastName  string
+}
+
+// Comment is a post comment.
+type Comment struct {
+	Author Author
+	Body   string
+}
+
+// Post is a blog post.
+type Post struct {
+	Title    string
+	Author   *Author
+	Body     string
+	Comments []Comment
+}
+
+// Upper is the upper helper.
+func Upper(s string) string {
+	return strings.ToUpper(s)
+}
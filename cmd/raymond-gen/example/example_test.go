@@ -0,0 +1,56 @@
+package example
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/yoinkai/raymond/v2"
+)
+
+func TestRenderPost(t *testing.T) {
+	t.Parallel()
+
+	source, err := os.ReadFile("templates/post.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := raymond.MustParse(string(source))
+	tpl.RegisterHelper("upper", Upper)
+
+	if err := tpl.RegisterPartialFile("templates/comment.hbs", "comment"); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := []*Post{
+		{
+			Title:  "Life <is> difficult",
+			Author: &Author{"Jean", "Valjean"},
+			Body:   "<p>Yes</p>",
+			Comments: []Comment{
+				{Author{"Marcel", "Beliveau"}, "LOL!"},
+				{Author{"Alan", "Johnson"}, "<script>"},
+			},
+		},
+		{
+			Title:  "No comment",
+			Author: &Author{"Jean", "Valjean"},
+		},
+		{
+			Title: "Anonymous",
+			Body:  "<p>No author</p>",
+		},
+	}
+
+	for _, post := range posts {
+		buf := new(bytes.Buffer)
+		if err := RenderPost(buf, post); err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := tpl.MustExec(post); buf.String() != expected {
+			t.Errorf("Generated template output differs from evaluated template\nexpected\n\t%q\ngot\n\t%q", expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Code generated by raymond-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"io"
+
+	"github.com/yoinkai/raymond/v2"
+)
+
+// RenderComment renders the "comment" template.
+func RenderComment(w io.Writer, ctx Comment) error {
+	if _, err := io.WriteString(w, "<b>"); err != nil {
+		return err
+	}
+	if !raymond.IsNil(ctx.Author) {
+		if _, err := io.WriteString(w, raymond.EscapeValue(ctx.Author.FirstName)); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "</b>: "); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.EscapeValue(ctx.Body)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenderPost renders the "post" template.
+func RenderPost(w io.Writer, ctx *Post) error {
+	if _, err := io.WriteString(w, "<div class=\"post\">\n  <h1>By "); err != nil {
+		return err
+	}
+	if !raymond.IsNil(ctx.Author) {
+		if _, err := io.WriteString(w, raymond.EscapeValue(ctx.Author.FirstName)); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, " "); err != nil {
+		return err
+	}
+	if !raymond.IsNil(ctx.Author) {
+		if _, err := io.WriteString(w, raymond.EscapeValue(Upper(ctx.Author.LastName))); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "</h1>\n  <div class=\"body\">"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.Str(ctx.Body)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "</div>\n"); err != nil {
+		return err
+	}
+	if raymond.IsTrue(ctx.Comments) {
+		if _, err := io.WriteString(w, "  <ul>\n"); err != nil {
+			return err
+		}
+		{
+			items1 := ctx.Comments
+			count5 := len(items1)
+			index4 := 0
+			for key3, item2 := range items1 {
+				_, _, _, _ = key3, item2, index4, count5
+				if _, err := io.WriteString(w, "    <li class=\""); err != nil {
+					return err
+				}
+				if raymond.IsTrue((index4 == 0)) {
+					if _, err := io.WriteString(w, "first"); err != nil {
+						return err
+					}
+				}
+				if _, err := io.WriteString(w, "\">"); err != nil {
+					return err
+				}
+				if _, err := io.WriteString(w, raymond.EscapeValue(index4)); err != nil {
+					return err
+				}
+				if _, err := io.WriteString(w, ". "); err != nil {
+					return err
+				}
+				if err := RenderComment(w, item2); err != nil {
+					return err
+				}
+				if _, err := io.WriteString(w, "</li>\n"); err != nil {
+					return err
+				}
+				index4++
+			}
+		}
+		if _, err := io.WriteString(w, "  </ul>\n"); err != nil {
+			return err
+		}
+	} else {
+		if _, err := io.WriteString(w, "  <p>No comments on "); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, raymond.EscapeValue(ctx.Title)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "</p>\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "  "); err != nil {
+		return err
+	}
+	if ctx6 := ctx.Author; raymond.IsTrue(ctx6) {
+		if _, err := io.WriteString(w, "<footer>"); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, raymond.EscapeValue(ctx6.FirstName)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, " wrote \""); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, raymond.EscapeValue(ctx.Title)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\"</footer>"); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "\n</div>\n"); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yoinkai/raymond/v2/ast"
+	"github.com/yoinkai/raymond/v2/parser"
+)
+
+// generator generates Go source code from several templates
+type generator struct {
+	// generated package name
+	pkg string
+
+	// generated functions names prefix
+	prefix string
+
+	// default context type
+	ctxType string
+
+	// context types by template name
+	types map[string]string
+
+	// Go functions by helper name
+	helpers map[string]string
+
+	// templates sources by name
+	sources map[string]string
+
+	// generated code calls the raymond package
+	usesRaymond bool
+}
+
+// newGenerator instanciates a new generator
+func newGenerator(pkg string) *generator {
+	return &generator{
+		pkg:     pkg,
+		prefix:  "Render",
+		types:   make(map[string]string),
+		helpers: make(map[string]string),
+		sources: make(map[string]string),
+	}
+}
+
+// addTemplate adds a template to generate
+func (g *generator) addTemplate(name string, source string) {
+	g.sources[name] = source
+}
+
+// funcName returns the name of the generated function for given template
+func (g *generator) funcName(name string) string {
+	result := g.prefix
+
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		result += strings.ToUpper(word[:1]) + word[1:]
+	}
+
+	return result
+}
+
+// contextType returns the context type of given template
+func (g *generator) contextType(name string) (string, error) {
+	if result := g.types[name]; result != "" {
+		return result, nil
+	}
+
+	if g.ctxType == "" {
+		return "", fmt.Errorf("%s: no context type", name)
+	}
+
+	return g.ctxType, nil
+}
+
+// generate generates formatted Go source code for all templates
+func (g *generator) generate() ([]byte, error) {
+	var names []string
+	for name := range g.sources {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// check functions names
+	funcs := make(map[string]string)
+	for _, name := range names {
+		funcName := g.funcName(name)
+		if other, ok := funcs[funcName]; ok {
+			return nil, fmt.Errorf("templates %s and %s both generate function %s", other, name, funcName)
+		}
+
+		funcs[funcName] = name
+	}
+
+	g.usesRaymond = false
+
+	body := new(bytes.Buffer)
+	for _, name := range names {
+		if err := g.generateTemplate(body, name); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "// Code generated by raymond-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", g.pkg)
+
+	if g.usesRaymond {
+		fmt.Fprintf(buf, "import (\n\t\"io\"\n\n\t\"github.com/yoinkai/raymond/v2\"\n)\n")
+	} else {
+		fmt.Fprintf(buf, "import \"io\"\n")
+	}
+
+	buf.Write(body.Bytes())
+
+	result, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %s", err)
+	}
+
+	return result, nil
+}
+
+// generateTemplate generates the function for given template
+func (g *generator) generateTemplate(buf *bytes.Buffer, name string) (err error) {
+	ctxType, err := g.contextType(name)
+	if err != nil {
+		return err
+	}
+
+	program, err := parser.Parse(g.sources[name])
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	tg := &templateGen{
+		gen:    g,
+		name:   name,
+		buf:    new(bytes.Buffer),
+		scopes: []*genScope{{ctx: "ctx"}},
+	}
+
+	if err = tg.generate(program); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(buf, "\n// %s renders the %q template.\n", g.funcName(name), name)
+	fmt.Fprintf(buf, "func %s(w io.Writer, ctx %s) error {\n", g.funcName(name), ctxType)
+	buf.Write(tg.buf.Bytes())
+	fmt.Fprintf(buf, "return nil\n}\n")
+
+	return nil
+}
+
+// genScope represents a context pushed by a block
+type genScope struct {
+	// context Go expression
+	ctx string
+
+	// iteration variables, set by #each
+	index string
+	key   string
+	count string
+
+	// Go variables by block parameter name
+	params map[string]string
+}
+
+// templateGen generates the body of a template function
+//
+// Statements visitors write code, expressions visitors return Go expressions.
+type templateGen struct {
+	gen  *generator
+	name string
+	buf  *bytes.Buffer
+
+	// contexts stack
+	scopes []*genScope
+
+	// nil checks of the fields traversed by the expressions of current statement
+	guards []string
+
+	// used to generate unique variables names
+	vars int
+}
+
+// generate generates code for given program
+func (tg *templateGen) generate(program *ast.Program) (err error) {
+	defer errRecover(&err)
+
+	program.Accept(tg)
+
+	return nil
+}
+
+// errRecover recovers generation panic
+func errRecover(errp *error) {
+	if e := recover(); e != nil {
+		switch err := e.(type) {
+		case runtime.Error:
+			panic(e)
+		case error:
+			*errp = err
+		default:
+			panic(e)
+		}
+	}
+}
+
+// errorf panics with an error located at given node
+func (tg *templateGen) errorf(node ast.Node, format string, args ...any) {
+	panic(fmt.Errorf("%s:%d: %s", tg.name, node.Location().Line, fmt.Sprintf(format, args...)))
+}
+
+// printf writes generated code
+func (tg *templateGen) printf(format string, args ...any) {
+	fmt.Fprintf(tg.buf, format, args...)
+}
+
+// write generates code that writes given Go string expression to output
+func (tg *templateGen) write(expr string) {
+	tg.printf("if _, err := io.WriteString(w, %s); err != nil {\nreturn err\n}\n", expr)
+}
+
+// guard returns the nil checks of the fields traversed by the expressions generated since last call, or an empty
+// string if there is none
+func (tg *templateGen) guard() string {
+	result := strings.Join(tg.guards, " && ")
+	tg.guards = nil
+
+	return result
+}
+
+// newVar returns a new unique variable name
+func (tg *templateGen) newVar(prefix string) string {
+	tg.vars++
+
+	return prefix + strconv.Itoa(tg.vars)
+}
+
+// raymond returns the Go expression of given raymond package function, and marks that package as imported
+func (tg *templateGen) raymond(name string) string {
+	tg.gen.usesRaymond = true
+
+	return "raymond." + name
+}
+
+// pushScope pushes a new context
+func (tg *templateGen) pushScope(scope *genScope) {
+	tg.scopes = append(tg.scopes, scope)
+}
+
+// popScope pops current context
+func (tg *templateGen) popScope() {
+	tg.scopes = tg.scopes[:len(tg.scopes)-1]
+}
+
+// blockParams returns the block parameters of given program, mapped to given Go variables
+func blockParams(program *ast.Program, vars ...string) map[string]string {
+	result := make(map[string]string)
+
+	for i, name := range program.BlockParams {
+		if i < len(vars) {
+			result[name] = vars[i]
+		}
+	}
+
+	return result
+}
+
+// fieldName returns the Go field name for given path part
+func (tg *templateGen) fieldName(node ast.Node, part string) string {
+	result := strings.ToUpper(part[:1]) + part[1:]
+	if !token.IsIdentifier(result) {
+		tg.errorf(node, "path part %q can't be converted to a Go field name", part)
+	}
+
+	return result
+}
+
+// path returns the Go expression for given path
+func (tg *templateGen) path(node *ast.PathExpression) string {
+	parts := node.Parts
+
+	var result string
+
+	switch {
+	case node.Data:
+		result = tg.dataPath(node)
+		parts = parts[1:]
+	case !node.Scoped && (node.Depth == 0) && (len(parts) > 0) && (tg.blockParam(parts[0]) != ""):
+		result = tg.blockParam(parts[0])
+		parts = parts[1:]
+	default:
+		i := len(tg.scopes) - 1 - node.Depth
+		if i < 0 {
+			tg.errorf(node, "path %q has no parent context", node.Original)
+		}
+
+		result = tg.scopes[i].ctx
+	}
+
+	for i, part := range parts {
+		if i > 0 {
+			// that field may be a nil pointer
+			tg.addGuard("!" + tg.raymond("IsNil") + "(" + result + ")")
+		}
+
+		result += "." + tg.fieldName(node, part)
+	}
+
+	return result
+}
+
+// addGuard adds a nil check to current statement
+func (tg *templateGen) addGuard(guard string) {
+	for _, g := range tg.guards {
+		if g == guard {
+			return
+		}
+	}
+
+	tg.guards = append(tg.guards, guard)
+}
+
+// blockParam returns the Go variable for given block parameter, or an empty string if not found
+func (tg *templateGen) blockParam(name string) string {
+	for i := len(tg.scopes) - 1; i >= 0; i-- {
+		if result := tg.scopes[i].params[name]; result != "" {
+			return result
+		}
+	}
+
+	return ""
+}
+
+// dataPath returns the Go expression for first part of given data path
+func (tg *templateGen) dataPath(node *ast.PathExpression) string {
+	name := node.Parts[0]
+
+	if name == "root" {
+		return tg.scopes[0].ctx
+	}
+
+	if len(node.Parts) > 1 {
+		tg.errorf(node, "data %q is not supported by raymond-gen", node.Original)
+	}
+
+	// find #each scope
+	depth := node.Depth
+	for i := len(tg.scopes) - 1; i > 0; i-- {
+		scope := tg.scopes[i]
+		if scope.index == "" {
+			continue
+		}
+
+		if depth > 0 {
+			depth--
+			continue
+		}
+
+		switch name {
+		case "index":
+			return scope.index
+		case "key":
+			return scope.key
+		case "first":
+			return "(" + scope.index + " == 0)"
+		case "last":
+			return "(" + scope.index + " == " + scope.count + "-1)"
+		}
+
+		break
+	}
+
+	tg.errorf(node, "data %q is not supported by raymond-gen", node.Original)
+	return ""
+}
+
+// expr returns the Go expression for given expression
+func (tg *templateGen) expr(node *ast.Expression) string {
+	result, _ := node.Accept(tg).(string)
+	return result
+}
+
+// param returns the Go expression for given helper parameter
+func (tg *templateGen) param(node ast.Node) string {
+	result, _ := node.Accept(tg).(string)
+	return result
+}
+
+//
+// Visitor interface
+//
+
+// Statements
+
+// VisitProgram implements corresponding Visitor interface method
+func (tg *templateGen) VisitProgram(node *ast.Program) any {
+	for _, n := range node.Body {
+		n.Accept(tg)
+	}
+
+	return nil
+}
+
+// VisitMustache implements corresponding Visitor interface method
+func (tg *templateGen) VisitMustache(node *ast.MustacheStatement) any {
+	expr := tg.expr(node.Expression)
+
+	guard := tg.guard()
+	if guard != "" {
+		tg.printf("if %s {\n", guard)
+	}
+
+	if node.Unescaped {
+		tg.write(tg.raymond("Str") + "(" + expr + ")")
+	} else {
+		tg.write(tg.raymond("EscapeValue") + "(" + expr + ")")
+	}
+
+	if guard != "" {
+		tg.printf("}\n")
+	}
+
+	return nil
+}
+
+// VisitBlock implements corresponding Visitor interface method
+func (tg *templateGen) VisitBlock(node *ast.BlockStatement) any {
+	name := node.Expression.HelperName()
+
+	if node.Expression.Hash != nil {
+		tg.errorf(node, "hash arguments are not supported by raymond-gen")
+	}
+
+	if (len(node.Expression.Params) != 1) || (name == "") {
+		tg.errorf(node, "block %q is not supported by raymond-gen, only #if, #unless, #with and #each with one parameter are", node.Expression.Canonical())
+	}
+
+	param := tg.param(node.Expression.Params[0])
+
+	// a nil field is falsy
+	guard := tg.guard()
+
+	switch name {
+	case "if":
+		if guard != "" {
+			tg.printf("if %s && %s(%s) {\n", guard, tg.raymond("IsTrue"), param)
+		} else {
+			tg.printf("if %s(%s) {\n", tg.raymond("IsTrue"), param)
+		}
+
+		tg.program(node.Program)
+		tg.inverse(node.Inverse)
+		tg.printf("}\n")
+	case "unless":
+		if guard != "" {
+			tg.printf("if !(%s) || !%s(%s) {\n", guard, tg.raymond("IsTrue"), param)
+		} else {
+			tg.printf("if !%s(%s) {\n", tg.raymond("IsTrue"), param)
+		}
+
+		tg.program(node.Program)
+		tg.inverse(node.Inverse)
+		tg.printf("}\n")
+	case "with":
+		ctx := tg.newVar("ctx")
+
+		if guard == "" {
+			tg.printf("if %s := %s; %s(%s) {\n", ctx, param, tg.raymond("IsTrue"), ctx)
+			tg.withProgram(node.Program, ctx)
+			tg.inverse(node.Inverse)
+			tg.printf("}\n")
+
+			break
+		}
+
+		// the else branch is rendered when the param is falsy or traverses a nil field
+		done := ""
+		if node.Inverse != nil {
+			done = tg.newVar("done")
+			tg.printf("{\n%s := false\n", done)
+		}
+
+		tg.printf("if %s {\nif %s := %s; %s(%s) {\n", guard, ctx, param, tg.raymond("IsTrue"), ctx)
+
+		if done != "" {
+			tg.printf("%s = true\n", done)
+		}
+
+		tg.withProgram(node.Program, ctx)
+		tg.printf("}\n}\n")
+
+		if done != "" {
+			tg.printf("if !%s {\n", done)
+			tg.program(node.Inverse)
+			tg.printf("}\n}\n")
+		}
+	case "each":
+		items, item, key, index, count := tg.newVar("items"), tg.newVar("item"), tg.newVar("key"), tg.newVar("index"), tg.newVar("count")
+
+		if guard != "" {
+			tg.printf("{\n%s := 0\nif %s {\n%s := %s\n%s = len(%s)\n%s := 0\n", count, guard, items, param, count, items, index)
+		} else {
+			tg.printf("{\n%s := %s\n%s := len(%s)\n%s := 0\n", items, param, count, items, index)
+		}
+
+		tg.printf("for %s, %s := range %s {\n", key, item, items)
+		tg.printf("_, _, _, _ = %s, %s, %s, %s\n", key, item, index, count)
+
+		if node.Program != nil {
+			tg.pushScope(&genScope{ctx: item, index: index, key: key, count: count, params: blockParams(node.Program, item, key)})
+			tg.program(node.Program)
+			tg.popScope()
+		}
+
+		tg.printf("%s++\n}\n", index)
+
+		if guard != "" {
+			tg.printf("}\n")
+		}
+
+		if node.Inverse != nil {
+			tg.printf("if %s == 0 {\n", count)
+			tg.program(node.Inverse)
+			tg.printf("}\n")
+		}
+
+		tg.printf("}\n")
+	default:
+		tg.errorf(node, "block %q is not supported by raymond-gen, only #if, #unless, #with and #each are", name)
+	}
+
+	return nil
+}
+
+// withProgram generates code for given #with block program, with given context
+func (tg *templateGen) withProgram(program *ast.Program, ctx string) {
+	if program != nil {
+		tg.pushScope(&genScope{ctx: ctx, params: blockParams(program, ctx)})
+		tg.program(program)
+		tg.popScope()
+	}
+}
+
+// program generates code for given block program
+func (tg *templateGen) program(program *ast.Program) {
+	if program != nil {
+		program.Accept(tg)
+	}
+}
+
+// inverse generates the else branch for given block inverse program
+func (tg *templateGen) inverse(program *ast.Program) {
+	if program != nil {
+		tg.printf("} else {\n")
+		program.Accept(tg)
+	}
+}
+
+// VisitPartial implements corresponding Visitor interface method
+func (tg *templateGen) VisitPartial(node *ast.PartialStatement) any {
+	if node.Program != nil {
+		tg.errorf(node, "partial blocks are not supported by raymond-gen")
+	}
+
+	name, ok := ast.HelperNameStr(node.Name)
+	if !ok {
+		tg.errorf(node, "dynamic partials are not supported by raymond-gen")
+	}
+
+	if _, ok := tg.gen.sources[name]; !ok {
+		tg.errorf(node, "partial %q not found", name)
+	}
+
+	if node.Hash != nil {
+		tg.errorf(node, "partial hash arguments are not supported by raymond-gen")
+	}
+
+	if node.Indent != "" {
+		tg.errorf(node, "indented standalone partials are not supported by raymond-gen")
+	}
+
+	ctx := tg.scopes[len(tg.scopes)-1].ctx
+
+	switch len(node.Params) {
+	case 0:
+	case 1:
+		ctx = tg.param(node.Params[0])
+	default:
+		tg.errorf(node, "partial %q called with more than one context", name)
+	}
+
+	// a partial whose context traverses a nil field is not rendered
+	if guard := tg.guard(); guard != "" {
+		tg.printf("if %s {\nif err := %s(w, %s); err != nil {\nreturn err\n}\n}\n", guard, tg.gen.funcName(name), ctx)
+	} else {
+		tg.printf("if err := %s(w, %s); err != nil {\nreturn err\n}\n", tg.gen.funcName(name), ctx)
+	}
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (tg *templateGen) VisitDecorator(node *ast.Decorator) any {
+	tg.errorf(node, "decorators and inline partials are not supported by raymond-gen")
+	return nil
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (tg *templateGen) VisitDecoratorBlock(node *ast.DecoratorBlock) any {
+	tg.errorf(node, "decorators and inline partials are not supported by raymond-gen")
+	return nil
+}
+
+// VisitContent implements corresponding Visitor interface method
+func (tg *templateGen) VisitContent(node *ast.ContentStatement) any {
+	if node.Value != "" {
+		tg.write(strconv.Quote(node.Value))
+	}
+
+	return nil
+}
+
+// VisitComment implements corresponding Visitor interface method
+func (tg *templateGen) VisitComment(node *ast.CommentStatement) any {
+	return nil
+}
+
+// Expressions
+
+// VisitExpression implements corresponding Visitor interface method
+func (tg *templateGen) VisitExpression(node *ast.Expression) any {
+	if node.Hash != nil {
+		tg.errorf(node, "hash arguments are not supported by raymond-gen")
+	}
+
+	// helper call
+	if name := node.HelperName(); name != "" {
+		if fn := tg.gen.helpers[name]; fn != "" {
+			var params []string
+			for _, param := range node.Params {
+				params = append(params, tg.param(param))
+			}
+
+			return fn + "(" + strings.Join(params, ", ") + ")"
+		}
+	}
+
+	if len(node.Params) > 0 {
+		tg.errorf(node, "unknown helper %q", node.Canonical())
+	}
+
+	path := node.FieldPath()
+	if path == nil {
+		tg.errorf(node, "literal expressions are not supported by raymond-gen")
+	}
+
+	return tg.path(path)
+}
+
+// VisitSubExpression implements corresponding Visitor interface method
+func (tg *templateGen) VisitSubExpression(node *ast.SubExpression) any {
+	return node.Expression.Accept(tg)
+}
+
+// VisitPath implements corresponding Visitor interface method
+func (tg *templateGen) VisitPath(node *ast.PathExpression) any {
+	return tg.path(node)
+}
+
+// Literals
+
+// VisitString implements corresponding Visitor interface method
+func (tg *templateGen) VisitString(node *ast.StringLiteral) any {
+	return strconv.Quote(node.Value)
+}
+
+// VisitBoolean implements corresponding Visitor interface method
+func (tg *templateGen) VisitBoolean(node *ast.BooleanLiteral) any {
+	return strconv.FormatBool(node.Value)
+}
+
+// VisitNumber implements corresponding Visitor interface method
+func (tg *templateGen) VisitNumber(node *ast.NumberLiteral) any {
+	return node.Original
+}
+
+// Miscellaneous
+
+// VisitHash implements corresponding Visitor interface method
+func (tg *templateGen) VisitHash(node *ast.Hash) any {
+	tg.errorf(node, "hash arguments are not supported by raymond-gen")
+	return nil
+}
+
+// VisitHashPair implements corresponding Visitor interface method
+func (tg *templateGen) VisitHashPair(node *ast.HashPair) any {
+	tg.errorf(node, "hash arguments are not supported by raymond-gen")
+	return nil
+}
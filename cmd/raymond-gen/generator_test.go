@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var generateErrors = []struct {
+	name   string
+	source string
+	err    string
+}{
+	{"parse error", "{{#if}}", "tpl: Parse error"},
+	{"unknown helper", "{{foo bar}}", "tpl:1: unknown helper \"foo\""},
+	{"hash", "{{upper name=1}}", "tpl:1: hash arguments are not supported"},
+	{"custom block", "\n{{#foo bar}}{{/foo}}", "tpl:2: block \"foo\" is not supported"},
+	{"section", "{{#items}}{{/items}}", "tpl:1: block \"items\" is not supported"},
+	{"unknown partial", "{{> missing}}", "tpl:1: partial \"missing\" not found"},
+	{"dynamic partial", "{{> (name)}}", "tpl:1: dynamic partials are not supported"},
+	{"parent context", "{{../name}}", "tpl:1: path \"../name\" has no parent context"},
+	{"field name", "{{first-name}}", "tpl:1: path part \"first-name\" can't be converted to a Go field name"},
+	{"data", "{{@index}}", "tpl:1: data \"@index\" is not supported"},
+	{"literal", `{{"foo"}}`, "tpl:1: literal expressions are not supported"},
+	{"inline partial", `{{#*inline "foo"}}{{/inline}}`, "tpl:1: decorators and inline partials are not supported"},
+	{"decorator", `{{* foo}}`, "tpl:1: decorators and inline partials are not supported"},
+	{"partial block", `{{#> foo}}bar{{/foo}}`, "tpl:1: partial blocks are not supported"},
+}
+
+func TestGenerateErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range generateErrors {
+		gen := newGenerator("test")
+		gen.ctxType = "T"
+		gen.helpers["upper"] = "Upper"
+		gen.addTemplate("tpl", test.source)
+
+		_, err := gen.generate()
+		if err == nil {
+			t.Errorf("Test '%s' failed - Error expected", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test '%s' failed - Incorrect error returned\nexpected\n\t%q\ngot\n\t%q", test.name, test.err, err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	gen := newGenerator("test")
+	gen.ctxType = "*T"
+	gen.types["emails/item"] = "Item"
+	gen.helpers["upper"] = "Upper"
+	gen.addTemplate("page", `{{#each items as |item|}}{{> emails/item item}}{{@key}}{{/each}}{{{upper (upper title) "x"}}}`)
+	gen.addTemplate("emails/item", `{{name}}{{@root.name}}`)
+
+	output, err := gen.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"func RenderPage(w io.Writer, ctx *T) error {",
+		"func RenderEmailsItem(w io.Writer, ctx Item) error {",
+		"for key3, item2 := range items1 {",
+		"if err := RenderEmailsItem(w, item2); err != nil {",
+		"raymond.EscapeValue(key3)",
+		`raymond.Str(Upper(Upper(ctx.Title), "x"))`,
+		"raymond.EscapeValue(ctx.Name)",
+	} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated code does not contain %q:\n%s", expected, output)
+		}
+	}
+}
+
+func TestGenerateNilGuards(t *testing.T) {
+	t.Parallel()
+
+	gen := newGenerator("test")
+	gen.ctxType = "*T"
+	gen.types["address"] = "Address"
+	gen.addTemplate("page", `{{author.name}}{{#unless author.admin}}x{{/unless}}{{#with author.address}}{{> address}}{{else}}y{{/with}}{{#each author.posts}}z{{/each}}`)
+	gen.addTemplate("address", `{{city.name}}`)
+
+	output, err := gen.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"if !raymond.IsNil(ctx.Author) {\n\t\tif _, err := io.WriteString(w, raymond.EscapeValue(ctx.Author.Name)); err != nil {",
+		"if !(!raymond.IsNil(ctx.Author)) || !raymond.IsTrue(ctx.Author.Admin) {",
+		"done2 := false\n\t\tif !raymond.IsNil(ctx.Author) {\n\t\t\tif ctx1 := ctx.Author.Address; raymond.IsTrue(ctx1) {\n\t\t\t\tdone2 = true",
+		"if !done2 {",
+		"count7 := 0\n\t\tif !raymond.IsNil(ctx.Author) {\n\t\t\titems3 := ctx.Author.Posts",
+		"if !raymond.IsNil(ctx.City) {",
+	} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated code does not contain %q:\n%s", expected, output)
+		}
+	}
+}
+
+func TestGenerateImports(t *testing.T) {
+	t.Parallel()
+
+	gen := newGenerator("test")
+	gen.ctxType = "T"
+	gen.addTemplate("page", `<h1>Home</h1>{{> footer}}`)
+	gen.addTemplate("footer", `{{! no mustache }}<footer></footer>`)
+
+	output, err := gen.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// raymond package is only imported when generated code calls it
+	if strings.Contains(string(output), "raymond.") || !strings.Contains(string(output), `import "io"`) {
+		t.Errorf("Unexpected imports in generated code:\n%s", output)
+	}
+
+	gen.addTemplate("title", `{{title}}`)
+
+	if output, err = gen.generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(output), `"github.com/yoinkai/raymond/v2"`) {
+		t.Errorf("Generated code does not import raymond:\n%s", output)
+	}
+}
+
+func TestGenerateExample(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "templates_gen.go")
+
+	args := []string{
+		"-pkg", "example",
+		"-dir", "example/templates",
+		"-type", "*Post",
+		"-type", "comment=Comment",
+		"-helper", "upper=Upper",
+		"-o", output,
+		"example/templates/*.hbs",
+	}
+
+	if err := run(args); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := os.ReadFile("example/templates_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(generated) != string(expected) {
+		t.Errorf("example/templates_gen.go is not up to date, run go generate")
+	}
+}
@@ -0,0 +1,175 @@
+// Command raymond-gen generates Go functions from handlebars templates.
+//
+// Each template is turned into a function that renders it straight to an io.Writer, with no parsing at runtime:
+//
+//	func RenderWelcome(w io.Writer, ctx *User) error
+//
+// The context is a declared Go type, and paths are converted to field selectors, like `{{author.firstName}}` to
+// `ctx.Author.FirstName`, so that a template that does not match its context type fails at build time. Helpers are
+// Go functions of the generated package, called directly.
+//
+// Usage:
+//
+//	//go:generate go run github.com/yoinkai/raymond/v2/cmd/raymond-gen -type *User -helper upper=Upper -o templates_gen.go templates/*.hbs
+//
+// Flags:
+//
+//	-o file          output file (default "templates_gen.go")
+//	-pkg name        package name (default $GOPACKAGE, set by go generate)
+//	-dir dir         directory that template names are relative to (default ".")
+//	-prefix prefix   functions names prefix (default "Render")
+//	-type type       context type of all templates, or name=type for one template
+//	-helper h=fn     Go function called for helper h
+//
+// A template name is its path relative to -dir, without extension, like `emails/header`, and its function name is
+// built from it, like `RenderEmailsHeader`. Partials refer to other templates by name.
+//
+// Only a subset of handlebars is supported, and generation fails with an error on anything else:
+//
+//   - content, comments, escaped and unescaped mustaches
+//   - paths, with `this`, `../` parent contexts, block parameters, and the `@root`, `@index`, `@key`, `@first` and
+//     `@last` data variables
+//   - `#if`, `#unless`, `#with` and `#each` blocks, with `{{else}}`
+//   - helper calls, with string, number, boolean, path and subexpression parameters
+//   - static partials, with an optional context parameter
+//
+// Unlike evaluation by raymond, a path is only resolved on its own context, and not on parent contexts.
+//
+// Fields selected on other fields are checked with raymond.IsNil() first, so that a nil pointer field renders like a
+// missing value: a mustache outputs nothing, and a block renders its else branch. A helper or a partial given such a
+// path as parameter is not called.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mapFlag is a repeatable flag of the form key=value
+type mapFlag map[string]string
+
+// String implements flag.Value interface
+func (f mapFlag) String() string {
+	var result []string
+	for key, value := range f {
+		result = append(result, key+"="+value)
+	}
+
+	return strings.Join(result, ",")
+}
+
+// Set implements flag.Value interface
+func (f mapFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+
+	f[parts[0]] = parts[1]
+
+	return nil
+}
+
+// typesFlag is a repeatable flag of the form type or name=type
+type typesFlag struct {
+	gen *generator
+}
+
+// String implements flag.Value interface
+func (f typesFlag) String() string {
+	if f.gen == nil {
+		return ""
+	}
+
+	return f.gen.ctxType
+}
+
+// Set implements flag.Value interface
+func (f typesFlag) Set(value string) error {
+	if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+		f.gen.types[parts[0]] = parts[1]
+	} else {
+		f.gen.ctxType = value
+	}
+
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "raymond-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command with given arguments
+func run(args []string) error {
+	gen := newGenerator(os.Getenv("GOPACKAGE"))
+
+	flags := flag.NewFlagSet("raymond-gen", flag.ContinueOnError)
+
+	output := flags.String("o", "templates_gen.go", "output file")
+	dir := flags.String("dir", ".", "directory that template names are relative to")
+	flags.StringVar(&gen.pkg, "pkg", gen.pkg, "package name")
+	flags.StringVar(&gen.prefix, "prefix", gen.prefix, "functions names prefix")
+	flags.Var(typesFlag{gen}, "type", "context type of all templates, or name=type for one template")
+	flags.Var(mapFlag(gen.helpers), "helper", "Go function called for a helper, as helper=function")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if gen.pkg == "" {
+		return fmt.Errorf("missing package name")
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no template")
+	}
+
+	for _, pattern := range flags.Args() {
+		filePaths, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		if filePaths == nil {
+			return fmt.Errorf("no template matches %s", pattern)
+		}
+
+		for _, filePath := range filePaths {
+			if err := addTemplateFile(gen, *dir, filePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	result, err := gen.generate()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(*output, result, 0o644)
+}
+
+// addTemplateFile reads given template file and adds it to generator
+func addTemplateFile(gen *generator, dir string, filePath string) error {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	name, err := filepath.Rel(dir, filePath)
+	if err != nil {
+		return err
+	}
+
+	name = filepath.ToSlash(strings.TrimSuffix(name, filepath.Ext(name)))
+
+	gen.addTemplate(name, string(b))
+
+	return nil
+}
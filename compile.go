@@ -0,0 +1,387 @@
+package raymond
+
+import (
+	"reflect"
+	"sync/atomic"
+
+	"github.com/yoinkai/raymond/v2/ast"
+)
+
+//
+// Compilation
+//
+// At parse time, programs are lowered to closures, so that evaluation does not walk the AST anymore. Node kinds are
+// resolved once, literals are pre-computed and helpers are looked up once and then cached until helpers registrations
+// change. Statements that are not compiled, like partials, fall back to the evalVisitor.
+//
+
+// compiledStatement evaluates a statement and writes result to current output
+type compiledStatement func(v *evalVisitor)
+
+// compiledExpr evaluates an expression, and tells if it was a helper call
+type compiledExpr func(v *evalVisitor) (any, bool)
+
+// compiledValue evaluates a helper parameter or hash value
+type compiledValue func(v *evalVisitor) any
+
+// compiledProgram is a program lowered to closures
+type compiledProgram struct {
+	statements []compiledStatement
+
+	// inline partials defined in program, nil if none
+	inlinePartials map[string]*partial
+
+	// decorators called when entering program
+	decorators []ast.Node
+}
+
+// compiledPrograms stores all compiled programs of a template, including nested ones
+type compiledPrograms map[*ast.Program]*compiledProgram
+
+// helpersGen is incremented each time a helper is registered or removed, and invalidates the helpers bound by
+// compiled expressions
+var helpersGen atomic.Uint64
+
+// helperBinding is a helper bound to a compiled expression
+type helperBinding struct {
+	gen    uint64
+	tpl    *Template
+	helper reflect.Value
+}
+
+// boundHelper caches the lookup of a helper by a compiled expression
+type boundHelper struct {
+	name    string
+	binding atomic.Pointer[helperBinding]
+}
+
+// find returns bound helper, looking it up again if helpers changed since it was bound
+func (b *boundHelper) find(v *evalVisitor) reflect.Value {
+	// helpers registered by decorators are not bound
+	if h := v.findDecoratedHelper(b.name); h != zero {
+		return h
+	}
+
+	gen := helpersGen.Load()
+
+	if binding := b.binding.Load(); (binding != nil) && (binding.gen == gen) && (binding.tpl == v.tpl) {
+		return binding.helper
+	}
+
+	helper := v.findHelper(b.name)
+	b.binding.Store(&helperBinding{gen: gen, tpl: v.tpl, helper: helper})
+
+	return helper
+}
+
+// compiler lowers a template AST to closures
+type compiler struct {
+	tpl      *Template
+	programs compiledPrograms
+}
+
+// compile compiles given template program
+func compile(tpl *Template, program *ast.Program) compiledPrograms {
+	c := &compiler{
+		tpl:      tpl,
+		programs: make(compiledPrograms),
+	}
+
+	c.compileProgram(program)
+
+	return c.programs
+}
+
+// compileProgram compiles given program
+func (c *compiler) compileProgram(node *ast.Program) {
+	if node == nil {
+		return
+	}
+
+	result := &compiledProgram{
+		inlinePartials: inlinePartials(node, c.tpl, c.programs),
+		decorators:     programDecorators(node),
+	}
+
+	for _, stmt := range node.Body {
+		stmt := stmt
+
+		var compiled compiledStatement
+
+		switch n := stmt.(type) {
+		case *ast.ContentStatement:
+			compiled = c.compileContent(n)
+		case *ast.MustacheStatement:
+			compiled = c.compileMustache(n)
+		case *ast.BlockStatement:
+			compiled = c.compileBlock(n)
+		case *ast.CommentStatement:
+			// ignore comments
+			continue
+		case *ast.Decorator:
+			// called when entering program
+			continue
+		case *ast.DecoratorBlock:
+			// defined or called when entering program
+			c.compileProgram(n.Program)
+			continue
+		case *ast.PartialStatement:
+			// partial block content
+			c.compileProgram(n.Program)
+
+			compiled = func(v *evalVisitor) {
+				stmt.Accept(v)
+			}
+		default:
+			compiled = func(v *evalVisitor) {
+				stmt.Accept(v)
+			}
+		}
+
+		result.statements = append(result.statements, compiled)
+	}
+
+	c.programs[node] = result
+}
+
+// compileContent compiles given content statement
+func (c *compiler) compileContent(node *ast.ContentStatement) compiledStatement {
+	value := node.Value
+
+	return func(v *evalVisitor) {
+		v.write(value)
+	}
+}
+
+// compileMustache compiles given mustache statement
+func (c *compiler) compileMustache(node *ast.MustacheStatement) compiledStatement {
+	expr := c.compileExpression(node.Expression)
+
+	return func(v *evalVisitor) {
+		v.at(node)
+
+		result, _ := expr(v)
+
+		v.writeMustache(node, result)
+	}
+}
+
+// compileBlock compiles given block statement
+func (c *compiler) compileBlock(node *ast.BlockStatement) compiledStatement {
+	expr := c.compileExpression(node.Expression)
+
+	c.compileProgram(node.Program)
+	c.compileProgram(node.Inverse)
+
+	return func(v *evalVisitor) {
+		v.at(node)
+
+		v.pushBlock(node)
+
+		result, isHelper := expr(v)
+
+		v.writeBlockResult(node, result, isHelper || v.wasFuncCall(node.Expression))
+
+		v.popBlock()
+	}
+}
+
+// compileExpression compiles given expression
+func (c *compiler) compileExpression(node *ast.Expression) compiledExpr {
+	var helper *boundHelper
+
+	params := c.compileParams(node.Params)
+	hash := c.compileHash(node.Hash)
+
+	if name := node.HelperName(); c.mayCallHelper(node, name) {
+		helper = &boundHelper{name: name}
+
+		// bind helper known at compile time
+		helper.binding.Store(&helperBinding{
+			gen:    helpersGen.Load(),
+			tpl:    c.tpl,
+			helper: c.findHelper(name),
+		})
+	}
+
+	literal, isLiteral := node.LiteralStr()
+	path := node.FieldPath()
+
+	return func(v *evalVisitor) (any, bool) {
+		v.at(node)
+
+		var result any
+		isHelper := false
+		done := false
+
+		v.pushExpr(node)
+
+		// helper call
+		if helper != nil {
+			if h := helper.find(v); h != zero {
+				options := newOptions(v, evalParams(v, params), evalHash(v, hash))
+				options.name = helper.name
+				options.expr = node
+
+				if val := v.callFunc(helper.name, h, options); val.IsValid() {
+					result = val.Interface()
+				}
+
+				isHelper = true
+				done = true
+			}
+		}
+
+		// literal
+		if !done && isLiteral {
+			if val := v.evalField(v.curCtx(), literal, true); val.IsValid() {
+				result = val.Interface()
+				done = true
+			}
+		}
+
+		// field path
+		if !done && (path != nil) {
+			result = v.evalPathExpression(path, true)
+		}
+
+		// helper not found
+		if !done && (result == nil) {
+			if h := v.findHelperMissing(node); h != zero {
+				options := newOptions(v, evalParams(v, params), evalHash(v, hash))
+				options.name = node.Canonical()
+				options.expr = node
+
+				result = v.callMissingHelper(helperMissingName, h, options)
+				isHelper = true
+			}
+		}
+
+		v.popExpr()
+
+		return result, isHelper
+	}
+}
+
+// mayCallHelper returns true if given expression may be a call of given helper
+//
+// In known helpers only mode, a mustache without params nor hash calls a helper only if that helper is known.
+func (c *compiler) mayCallHelper(node *ast.Expression, name string) bool {
+	if name == "" {
+		return false
+	}
+
+	if c.tpl.parseOpts.knownHelpersOnly && (len(node.Params) == 0) && (node.Hash == nil) {
+		return c.tpl.isKnownHelper(name)
+	}
+
+	return true
+}
+
+// findHelper finds given helper at compile time
+func (c *compiler) findHelper(name string) reflect.Value {
+	if h := c.tpl.findHelper(name); h != zero {
+		return h
+	}
+
+	return c.tpl.env.findHelper(name)
+}
+
+// compileParams compiles given helper parameters
+func (c *compiler) compileParams(nodes []ast.Node) []compiledValue {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	result := make([]compiledValue, len(nodes))
+	for i, node := range nodes {
+		result[i] = c.compileValue(node)
+	}
+
+	return result
+}
+
+// compiledHashPair is a compiled helper hash argument
+type compiledHashPair struct {
+	key   string
+	value compiledValue
+}
+
+// compileHash compiles given helper hash arguments
+func (c *compiler) compileHash(node *ast.Hash) []compiledHashPair {
+	if node == nil {
+		return nil
+	}
+
+	result := make([]compiledHashPair, len(node.Pairs))
+	for i, pair := range node.Pairs {
+		result[i] = compiledHashPair{key: pair.Key, value: c.compileValue(pair.Val)}
+	}
+
+	return result
+}
+
+// compileValue compiles given helper parameter or hash value
+func (c *compiler) compileValue(node ast.Node) compiledValue {
+	switch n := node.(type) {
+	case *ast.StringLiteral:
+		value := n.Value
+		return func(v *evalVisitor) any { return value }
+	case *ast.BooleanLiteral:
+		value := n.Value
+		return func(v *evalVisitor) any { return value }
+	case *ast.NumberLiteral:
+		value := n.Number()
+		return func(v *evalVisitor) any { return value }
+	case *ast.PathExpression:
+		return func(v *evalVisitor) any {
+			if v.opts.stringParams {
+				return n.Original
+			}
+
+			return v.evalPathExpression(n, false)
+		}
+	case *ast.SubExpression:
+		expr := c.compileExpression(n.Expression)
+		return func(v *evalVisitor) any {
+			v.at(n)
+
+			result, _ := expr(v)
+			return result
+		}
+	}
+
+	return func(v *evalVisitor) any {
+		return node.Accept(v)
+	}
+}
+
+// evalParams evaluates compiled helper parameters
+func evalParams(v *evalVisitor, params []compiledValue) []any {
+	if len(params) == 0 {
+		return nil
+	}
+
+	result := make([]any, len(params))
+	for i, param := range params {
+		result[i] = param(v)
+	}
+
+	return result
+}
+
+// evalHash evaluates compiled helper hash arguments
+func evalHash(v *evalVisitor, hash []compiledHashPair) map[string]any {
+	if hash == nil {
+		return nil
+	}
+
+	result := make(map[string]any, len(hash))
+	for _, pair := range hash {
+		if value := pair.value(v); value != nil {
+			result[pair.key] = value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,54 @@
+package raymond
+
+import "testing"
+
+func TestCompiledHelperBinding(t *testing.T) {
+	ctx := map[string]string{"compileTestFoo": "field"}
+
+	tpl := MustParse("{{compileTestFoo}}")
+	if output := tpl.MustExec(ctx); output != "field" {
+		t.Errorf("Unexpected output before helper registration: %q", output)
+	}
+
+	// helper registered after compilation
+	RegisterHelper("compileTestFoo", func() string { return "global" })
+	if output := tpl.MustExec(ctx); output != "global" {
+		t.Errorf("Unexpected output after global helper registration: %q", output)
+	}
+
+	tpl.RegisterHelper("compileTestFoo", func() string { return "template" })
+	if output := tpl.MustExec(ctx); output != "template" {
+		t.Errorf("Unexpected output after template helper registration: %q", output)
+	}
+
+	// helper removed after compilation
+	RemoveHelper("compileTestFoo")
+	if output := MustParse("{{compileTestFoo}}").MustExec(ctx); output != "field" {
+		t.Errorf("Unexpected output after helper removal: %q", output)
+	}
+}
+
+func TestCompiledPartialHelpers(t *testing.T) {
+	t.Parallel()
+
+	partial := MustParse("{{name}}")
+
+	tpl1 := MustParse("{{> p}}")
+	tpl1.RegisterPartialTemplate("p", partial)
+	tpl1.RegisterHelper("name", func() string { return "one" })
+
+	tpl2 := MustParse("{{> p}}")
+	tpl2.RegisterPartialTemplate("p", partial)
+	tpl2.RegisterHelper("name", func() string { return "two" })
+
+	// the same compiled partial must use helpers of the template being evaluated
+	for i := 0; i < 2; i++ {
+		if output := tpl1.MustExec(nil); output != "one" {
+			t.Errorf("Unexpected output with first template: %q", output)
+		}
+
+		if output := tpl2.MustExec(nil); output != "two" {
+			t.Errorf("Unexpected output with second template: %q", output)
+		}
+	}
+}
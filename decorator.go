@@ -0,0 +1,100 @@
+package raymond
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/yoinkai/raymond/v2/ast"
+)
+
+// Decorator represents a decorator function.
+//
+// A decorator is called with `{{* name args}}` or `{{#* name args}}...{{/name}}`, before the evaluation of the program
+// containing it. It can register helpers and partials, and set private data, that are only available to that program.
+type Decorator func(options *DecoratorOptions)
+
+// DecoratorOptions represents the options argument provided to decorators.
+//
+// Params and hash of the decorator are available like for helpers. For a decorator block, Fn() evaluates the block
+// content. DataFrame() returns the private data frame of the decorated program, so values set on it are only visible
+// in that program.
+type DecoratorOptions struct {
+	*Options
+
+	helpers  map[string]reflect.Value
+	partials map[string]*partial
+}
+
+// RegisterDecorator registers a global decorator. That decorator will be available to all templates of the default
+// environment.
+func RegisterDecorator(name string, decorator Decorator) {
+	defaultEnv.RegisterDecorator(name, decorator)
+}
+
+// RegisterDecorators registers several global decorators. Those decorators will be available to all templates of the
+// default environment.
+func RegisterDecorators(decorators map[string]Decorator) {
+	defaultEnv.RegisterDecorators(decorators)
+}
+
+// RemoveDecorator unregisters a global decorator
+func RemoveDecorator(name string) {
+	defaultEnv.RemoveDecorator(name)
+}
+
+// RemoveAllDecorators unregisters all global decorators
+func RemoveAllDecorators() {
+	defaultEnv.RemoveAllDecorators()
+}
+
+// newDecoratorOptions instanciates a new DecoratorOptions
+func newDecoratorOptions(options *Options) *DecoratorOptions {
+	return &DecoratorOptions{
+		Options:  options,
+		helpers:  make(map[string]reflect.Value),
+		partials: make(map[string]*partial),
+	}
+}
+
+// RegisterHelper registers a helper for the decorated program.
+func (options *DecoratorOptions) RegisterHelper(name string, helper any) {
+	val := reflect.ValueOf(helper)
+	ensureValidHelper(name, val)
+
+	options.helpers[name] = val
+}
+
+// RegisterPartial registers a partial for the decorated program.
+func (options *DecoratorOptions) RegisterPartial(name string, source string) {
+	options.partials[name] = newPartial(name, source, options.eval.tpl.env, nil)
+}
+
+// RegisterPartialTemplate registers an already parsed partial for the decorated program.
+func (options *DecoratorOptions) RegisterPartialTemplate(name string, tpl *Template) {
+	options.partials[name] = newPartial(name, "", options.eval.tpl.env, tpl)
+}
+
+// programDecorators returns the decorators of given program, inline partials definitions excepted
+func programDecorators(node *ast.Program) []ast.Node {
+	var result []ast.Node
+
+	for _, stmt := range node.Body {
+		switch n := stmt.(type) {
+		case *ast.Decorator:
+			result = append(result, n)
+		case *ast.DecoratorBlock:
+			if _, ok := inlinePartialName(n); !ok {
+				result = append(result, n)
+			}
+		}
+	}
+
+	return result
+}
+
+// ensureValidDecorator panics if given decorator is not valid
+func ensureValidDecorator(name string, decorator Decorator) {
+	if decorator == nil {
+		panic(fmt.Errorf("decorator can't be nil: %s", name))
+	}
+}
@@ -0,0 +1,190 @@
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+//
+// Decorators
+//
+
+var translations = map[string]map[string]string{
+	"en": {"hello": "hello"},
+	"fr": {"hello": "bonjour"},
+}
+
+func localeDecorator(options *DecoratorOptions) {
+	locale := options.ParamStr(0)
+
+	options.DataFrame().Set("locale", locale)
+	options.RegisterHelper("t", func(key string) string {
+		return translations[locale][key]
+	})
+}
+
+func setDecorator(options *DecoratorOptions) {
+	options.DataFrame().Set(options.ParamStr(0), options.Param(1))
+}
+
+func captureDecorator(options *DecoratorOptions) {
+	options.DataFrame().Set(options.ParamStr(0), options.Fn())
+}
+
+func partialDecorator(options *DecoratorOptions) {
+	options.RegisterPartial(options.ParamStr(0), options.HashStr("source"))
+}
+
+var testDecorators = map[string]Decorator{
+	"locale":  localeDecorator,
+	"set":     setDecorator,
+	"capture": captureDecorator,
+	"partial": partialDecorator,
+}
+
+//
+// Tests
+//
+
+var decoratorTests = []struct {
+	name   string
+	input  string
+	data   any
+	output string
+}{
+	{
+		"decorator at root",
+		`{{* set "foo" "bar"}}{{@foo}}`,
+		nil,
+		"bar",
+	},
+	{
+		"decorator called before program evaluation",
+		`{{@foo}}{{* set "foo" "bar"}}`,
+		nil,
+		"bar",
+	},
+	{
+		"decorator params evaluated with program context",
+		`{{#with user}}{{* set "who" name}}{{@who}}{{/with}}`,
+		map[string]any{"user": map[string]string{"name": "Jon"}},
+		"Jon",
+	},
+	{
+		"decorator data frame scoped to program",
+		`{{#with user}}{{* set "who" name}}{{@who}}{{/with}}-{{@who}}`,
+		map[string]any{"user": map[string]string{"name": "Jon"}},
+		"Jon-",
+	},
+	{
+		"decorator data frame available to nested programs",
+		`{{* set "sep" ", "}}{{#each items}}{{#unless @first}}{{@sep}}{{/unless}}{{.}}{{/each}}`,
+		map[string]any{"items": []string{"a", "b", "c"}},
+		"a, b, c",
+	},
+	{
+		"decorator helpers scoped to program",
+		`{{t "hello"}} {{#with .}}{{* locale "fr"}}{{t "hello"}} {{@locale}}{{/with}} {{t "hello"}}`,
+		map[string]string{"foo": "bar"},
+		"hi bonjour fr hi",
+	},
+	{
+		"nested decorators",
+		`{{* locale "fr"}}{{t "hello"}} {{#with .}}{{* locale "en"}}{{t "hello"}}{{/with}} {{t "hello"}}`,
+		map[string]string{"foo": "bar"},
+		"bonjour hello bonjour",
+	},
+	{
+		"decorator partials",
+		`{{* partial "greet" source="hi {{name}}"}}{{> greet}}`,
+		map[string]string{"name": "Jon"},
+		"hi Jon",
+	},
+	{
+		"decorator partials scoped to program",
+		`{{#with .}}{{* partial "greet" source="inner"}}{{> greet}}{{/with}} {{> greet}}`,
+		map[string]string{"foo": "bar"},
+		"inner outer",
+	},
+	{
+		"decorator block",
+		`{{#* capture "title"}}Hello {{name}}{{/capture}}<h1>{{@title}}</h1>`,
+		map[string]string{"name": "Jon"},
+		"<h1>Hello Jon</h1>",
+	},
+	{
+		"standalone decorator block",
+		"{{#* capture \"title\"}}\nHello {{name}}\n{{/capture}}\n<h1>{{@title}}</h1>",
+		map[string]string{"name": "Jon"},
+		"<h1>Hello Jon\n</h1>",
+	},
+}
+
+func TestDecorators(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range decoratorTests {
+		tpl, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Failed to parse template: %s", test.name, err)
+			continue
+		}
+
+		tpl.RegisterDecorators(testDecorators)
+		tpl.RegisterHelper("t", func(key string) string { return "hi" })
+		tpl.RegisterPartial("greet", "outer")
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestDecoratorNotFound(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{* missing}}`)
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Decorator not found: missing") {
+		t.Errorf("Unexpected error for an unknown decorator: %v", err)
+	}
+}
+
+func TestDecoratorRegistration(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.RegisterDecorator("set", setDecorator)
+	env.RegisterDecorator("name", func(options *DecoratorOptions) { options.DataFrame().Set("name", "environment") })
+
+	tpl := env.MustParse(`{{* name}}{{@name}}`)
+	if output := tpl.MustExec(nil); output != "environment" {
+		t.Errorf("Unexpected output with environment decorator: %q", output)
+	}
+
+	tpl.RegisterDecorator("name", func(options *DecoratorOptions) { options.DataFrame().Set("name", "template") })
+	if output := tpl.MustExec(nil); output != "template" {
+		t.Errorf("Template decorator must override environment decorator, got: %q", output)
+	}
+
+	if output := tpl.Clone().MustExec(nil); output != "template" {
+		t.Errorf("Unexpected output with cloned template: %q", output)
+	}
+
+	env.RemoveDecorator("name")
+	if output := env.MustParse(`{{* set "name" "set"}}{{@name}}`).MustExec(nil); output != "set" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	env.RemoveAllDecorators()
+	if _, err := env.MustParse(`{{* set "name" "set"}}`).Exec(nil); err == nil {
+		t.Errorf("Error expected after decorators removal")
+	}
+
+	// not registered in default environment
+	if _, err := MustParse(`{{* set "name" "set"}}`).Exec(nil); err == nil {
+		t.Errorf("Error expected for a decorator of another environment")
+	}
+}
@@ -0,0 +1,534 @@
+package raymond
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Environment holds the helpers, param helpers, partials, decorators, logger and evaluation options available to the
+// templates parsed with it.
+//
+// Environments are isolated from each other, so that several libraries of a program can register helpers and partials
+// with the same names. Package level functions, like Parse() and RegisterHelper(), use a default environment.
+type Environment struct {
+	helpers      map[string]reflect.Value
+	paramHelpers map[string]paramHelperFunc
+	partials     map[string]*partial
+	decorators   map[string]Decorator
+	logger       *logrus.Entry
+
+	// default evaluation options of templates
+	opts execOptions
+
+	// parsing options of templates
+	parseOpts parseOptions
+
+	// protects helpers, param helpers, partials, decorators, logger, opts and parseOpts
+	mutex sync.RWMutex
+}
+
+// defaultEnv is the environment used by package level functions
+var defaultEnv = NewEnvironment()
+
+// NewEnvironment instanciates a new environment, with built-in helpers registered.
+func NewEnvironment() *Environment {
+	env := &Environment{
+		helpers:      make(map[string]reflect.Value),
+		paramHelpers: make(map[string]paramHelperFunc),
+		partials:     make(map[string]*partial),
+		decorators:   make(map[string]Decorator),
+		logger:       logrus.NewEntry(logrus.StandardLogger()),
+	}
+
+	// register builtin helpers
+	env.RegisterHelper("if", ifHelper)
+	env.RegisterHelper("unless", unlessHelper)
+	env.RegisterHelper("with", withHelper)
+	env.RegisterHelper("each", eachHelper)
+	env.RegisterHelper("log", logHelper)
+	env.RegisterHelper("lookup", lookupHelper)
+	env.RegisterHelper("equal", equalHelper)
+	env.RegisterHelper("ifGt", ifGtHelper)
+	env.RegisterHelper("ifLt", ifLtHelper)
+	env.RegisterHelper("ifEq", ifEqHelper)
+	env.RegisterHelper("ifMatchesRegexStr", ifMatchesRegexStr)
+	env.RegisterHelper("pluralize", pluralizeHelper)
+
+	// register builtin param helpers
+	env.RegisterParamHelper("length", lengthParamHelper)
+
+	return env
+}
+
+// DefaultEnvironment returns the environment used by package level functions.
+func DefaultEnvironment() *Environment {
+	return defaultEnv
+}
+
+//
+// Parsing
+//
+
+// Parse instanciates a template bound to that environment by parsing given source.
+func (env *Environment) Parse(source string) (*Template, error) {
+	return env.parse("", source)
+}
+
+// parse instanciates a template bound to that environment with given name, by parsing given source
+func (env *Environment) parse(name string, source string) (*Template, error) {
+	tpl := newTemplate(source)
+	tpl.name = name
+	tpl.env = env
+	tpl.opts = env.execOptions()
+	tpl.parseOpts = env.parseOptions()
+
+	// parse template
+	if err := tpl.parse(); err != nil {
+		return nil, err
+	}
+
+	return tpl, nil
+}
+
+// MustParse instanciates a template bound to that environment by parsing given source. It panics on error.
+func (env *Environment) MustParse(source string) *Template {
+	result, err := env.Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ParseHTML instanciates a template bound to that environment by parsing given source, with contextual HTML escaping
+// enabled.
+//
+// See ParseHTML().
+func (env *Environment) ParseHTML(source string) (*Template, error) {
+	tpl, err := env.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tpl.contextEscapers(); err != nil {
+		return nil, err
+	}
+
+	tpl.html = true
+
+	return tpl, nil
+}
+
+// MustParseHTML instanciates a template bound to that environment by parsing given source, with contextual HTML
+// escaping enabled. It panics on error.
+func (env *Environment) MustParseHTML(source string) *Template {
+	result, err := env.ParseHTML(source)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ParseFile reads given file and returns parsed template bound to that environment.
+func (env *Environment) ParseFile(filePath string) (*Template, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return env.parse(filePath, string(b))
+}
+
+// ParseFS reads given file from fsys and returns parsed template bound to that environment.
+func (env *Environment) ParseFS(fsys fs.FS, name string) (*Template, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return env.parse(name, string(b))
+}
+
+// Render parses a template bound to that environment and evaluates it with given context.
+func (env *Environment) Render(source string, ctx any) (string, error) {
+	tpl, err := env.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	return tpl.Exec(ctx)
+}
+
+// NewLoader instanciates a loader for the files of fsys, whose templates are bound to that environment.
+//
+// See NewLoader().
+func (env *Environment) NewLoader(fsys fs.FS, extensions ...string) (*Loader, error) {
+	if len(extensions) == 0 {
+		extensions = defaultPartialsExtensions
+	}
+
+	result := &Loader{
+		env:        env,
+		fsys:       fsys,
+		extensions: extensions,
+	}
+
+	if err := result.Reload(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+//
+// Options
+//
+
+// SetStrict enables or disables strict mode for templates parsed afterwards with that environment.
+//
+// See Template.SetStrict().
+func (env *Environment) SetStrict(strict bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.strict = strict
+}
+
+// SetAssumeObjects enables or disables assume objects mode for templates parsed afterwards with that environment.
+//
+// See Template.SetAssumeObjects().
+func (env *Environment) SetAssumeObjects(assumeObjects bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.assumeObjects = assumeObjects
+}
+
+// SetCompat enables or disables compat mode for templates parsed afterwards with that environment.
+//
+// See Template.SetCompat().
+func (env *Environment) SetCompat(compat bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.compat = compat
+}
+
+// SetTrackIDs enables or disables trackIds mode for templates parsed afterwards with that environment.
+//
+// See Template.SetTrackIDs().
+func (env *Environment) SetTrackIDs(trackIDs bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.trackIDs = trackIDs
+}
+
+// SetStringParams enables or disables stringParams mode for templates parsed afterwards with that environment.
+//
+// See Template.SetStringParams().
+func (env *Environment) SetStringParams(stringParams bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.stringParams = stringParams
+}
+
+// SetPreventIndent enables or disables preventIndent mode for templates parsed afterwards with that environment.
+//
+// See Template.SetPreventIndent().
+func (env *Environment) SetPreventIndent(preventIndent bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.preventIndent = preventIndent
+}
+
+// SetEscaper sets the mustaches escaper for templates parsed afterwards with that environment.
+//
+// See Template.SetEscaper().
+func (env *Environment) SetEscaper(escaper Escaper) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.opts.escaper = escaper
+}
+
+// execOptions returns a copy of default evaluation options
+func (env *Environment) execOptions() execOptions {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.opts
+}
+
+// SetKnownHelpers declares helpers that are known to exist when templates parsed afterwards with that environment are
+// evaluated, like helpers registered on templates after parsing, or by decorators.
+//
+// Helpers registered on that environment are always known. See SetKnownHelpersOnly().
+func (env *Environment) SetKnownHelpers(names ...string) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.parseOpts.knownHelpers = make(map[string]bool, len(names))
+	for _, name := range names {
+		env.parseOpts.knownHelpers[name] = true
+	}
+}
+
+// SetKnownHelpersOnly enables or disables known helpers only mode for templates parsed afterwards with that
+// environment.
+//
+// In that mode, parsing fails with an error when a template calls a helper that is neither registered on that
+// environment nor declared with SetKnownHelpers(). A helper call is a mustache or a block with params or hash
+// arguments, like `{{formatDate x}}` or `{{#list items}}`, or a subexpression. Other mustaches and blocks, like
+// `{{title}}` or `{{#user}}`, are then always evaluated as fields and sections, without looking for a helper.
+//
+// Template.CheckHelpers() does the same check, including helpers registered on template.
+func (env *Environment) SetKnownHelpersOnly(knownHelpersOnly bool) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.parseOpts.knownHelpersOnly = knownHelpersOnly
+}
+
+// parseOptions returns a copy of templates parsing options
+func (env *Environment) parseOptions() parseOptions {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.parseOpts.clone()
+}
+
+// SetLogger sets the logger used by helpers of templates bound to that environment.
+func (env *Environment) SetLogger(entry *logrus.Entry) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.logger = entry
+}
+
+// log returns the logger
+func (env *Environment) log() *logrus.Entry {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.logger
+}
+
+//
+// Helpers
+//
+
+// RegisterHelper registers a helper that will be available to all templates bound to that environment.
+func (env *Environment) RegisterHelper(name string, helper any) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	if env.helpers[name] != zero {
+		panic(fmt.Errorf("helper already registered: %s", name))
+	}
+
+	val := reflect.ValueOf(helper)
+	ensureValidHelper(name, val)
+
+	env.helpers[name] = val
+
+	helpersGen.Add(1)
+}
+
+// RegisterHelpers registers several helpers that will be available to all templates bound to that environment.
+func (env *Environment) RegisterHelpers(helpers map[string]any) {
+	for name, helper := range helpers {
+		env.RegisterHelper(name, helper)
+	}
+}
+
+// RegisterLayoutHelpers registers the extend, block and content layout helpers in that environment.
+func (env *Environment) RegisterLayoutHelpers() {
+	env.RegisterHelpers(layoutHelpers())
+}
+
+// RemoveHelper unregisters a helper of that environment.
+func (env *Environment) RemoveHelper(name string) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	delete(env.helpers, name)
+
+	helpersGen.Add(1)
+}
+
+// RemoveAllHelpers unregisters all helpers of that environment, including built-in ones.
+func (env *Environment) RemoveAllHelpers() {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.helpers = make(map[string]reflect.Value)
+
+	helpersGen.Add(1)
+}
+
+// findHelper finds a helper of that environment
+func (env *Environment) findHelper(name string) reflect.Value {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.helpers[name]
+}
+
+// RegisterParamHelper registers a param helper that will be available to all templates bound to that environment.
+func (env *Environment) RegisterParamHelper(name string, helper paramHelperFunc) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	if _, ok := env.paramHelpers[name]; ok {
+		panic(fmt.Errorf("Param helper already registered: %s", name))
+	}
+
+	env.paramHelpers[name] = helper
+}
+
+// RemoveParamHelper unregisters a param helper of that environment.
+func (env *Environment) RemoveParamHelper(name string) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	delete(env.paramHelpers, name)
+}
+
+// findParamHelper finds a param helper of that environment
+func (env *Environment) findParamHelper(name string) paramHelperFunc {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.paramHelpers[name]
+}
+
+//
+// Decorators
+//
+
+// RegisterDecorator registers a decorator that will be available to all templates bound to that environment.
+func (env *Environment) RegisterDecorator(name string, decorator Decorator) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	if env.decorators[name] != nil {
+		panic(fmt.Errorf("decorator already registered: %s", name))
+	}
+
+	ensureValidDecorator(name, decorator)
+
+	env.decorators[name] = decorator
+}
+
+// RegisterDecorators registers several decorators that will be available to all templates bound to that environment.
+func (env *Environment) RegisterDecorators(decorators map[string]Decorator) {
+	for name, decorator := range decorators {
+		env.RegisterDecorator(name, decorator)
+	}
+}
+
+// RemoveDecorator unregisters a decorator of that environment.
+func (env *Environment) RemoveDecorator(name string) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	delete(env.decorators, name)
+}
+
+// RemoveAllDecorators unregisters all decorators of that environment.
+func (env *Environment) RemoveAllDecorators() {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.decorators = make(map[string]Decorator)
+}
+
+// findDecorator finds a decorator of that environment
+func (env *Environment) findDecorator(name string) Decorator {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.decorators[name]
+}
+
+//
+// Partials
+//
+
+// RegisterPartial registers a partial that will be available to all templates bound to that environment.
+func (env *Environment) RegisterPartial(name string, source string) {
+	env.addPartial(name, source, nil)
+}
+
+// RegisterPartials registers several partials that will be available to all templates bound to that environment.
+func (env *Environment) RegisterPartials(partials map[string]string) {
+	for name, p := range partials {
+		env.RegisterPartial(name, p)
+	}
+}
+
+// RegisterPartialsFS reads the files matching given pattern in fsys, and registers them as partials of that
+// environment.
+//
+// See Template.RegisterPartialsFS() for pattern, extensions and partials names.
+func (env *Environment) RegisterPartialsFS(fsys fs.FS, pattern string, extensions ...string) error {
+	sources, err := readPartialsFS(fsys, pattern, extensions)
+	if err != nil {
+		return err
+	}
+
+	env.RegisterPartials(sources)
+
+	return nil
+}
+
+// RegisterPartialTemplate registers a partial with given parsed template, that will be available to all templates
+// bound to that environment.
+func (env *Environment) RegisterPartialTemplate(name string, tpl *Template) {
+	env.addPartial(name, "", tpl)
+}
+
+// addPartial registers a partial
+func (env *Environment) addPartial(name string, source string, tpl *Template) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	if env.partials[name] != nil {
+		panic(fmt.Errorf("partial already registered: %s", name))
+	}
+
+	env.partials[name] = newPartial(name, source, env, tpl)
+}
+
+// RemovePartial removes a partial of that environment. This does not affect partials registered on a specific
+// template.
+func (env *Environment) RemovePartial(name string) {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	delete(env.partials, name)
+}
+
+// RemoveAllPartials removes all partials of that environment. This does not affect partials registered on a specific
+// template.
+func (env *Environment) RemoveAllPartials() {
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	env.partials = make(map[string]*partial)
+}
+
+// findPartial finds a partial of that environment
+func (env *Environment) findPartial(name string) *partial {
+	env.mutex.RLock()
+	defer env.mutex.RUnlock()
+
+	return env.partials[name]
+}
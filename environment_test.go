@@ -0,0 +1,191 @@
+package raymond
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestEnvironmentIsolation(t *testing.T) {
+	t.Parallel()
+
+	env1 := NewEnvironment()
+	env1.RegisterHelper("formatDate", func() string { return "one" })
+	env1.RegisterPartial("footer", "footer one")
+
+	// same names registered in another environment
+	env2 := NewEnvironment()
+	env2.RegisterHelper("formatDate", func() string { return "two" })
+	env2.RegisterPartial("footer", "footer two")
+
+	source := `{{formatDate}} {{> footer}}`
+
+	if output := env1.MustParse(source).MustExec(nil); output != "one footer one" {
+		t.Errorf("Unexpected output with first environment: %q", output)
+	}
+
+	if output := env2.MustParse(source).MustExec(nil); output != "two footer two" {
+		t.Errorf("Unexpected output with second environment: %q", output)
+	}
+
+	// not registered in default environment
+	if output := MustParse(`{{formatDate}}`).MustExec(map[string]string{"formatDate": "field"}); output != "field" {
+		t.Errorf("Unexpected output with default environment: %q", output)
+	}
+
+	if _, err := MustParse(`{{> footer}}`).Exec(nil); err == nil {
+		t.Errorf("Error expected for a partial of another environment")
+	}
+}
+
+func TestEnvironmentBuiltinHelpers(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+
+	tpl := env.MustParse(`{{#if ok}}{{items.length}}{{/if}}`)
+	if output := tpl.MustExec(map[string]any{"ok": true, "items": []int{1, 2}}); output != "2" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	env.RemoveAllHelpers()
+
+	if output := tpl.MustExec(map[string]any{"ok": true, "items": []int{1, 2}}); output != "" {
+		t.Errorf("Unexpected output after helpers removal: %q", output)
+	}
+
+	if DefaultEnvironment().findHelper("if") == zero {
+		t.Errorf("Default environment helpers removed")
+	}
+}
+
+func TestEnvironmentTemplateHelpers(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.RegisterHelper("name", func() string { return "environment" })
+
+	tpl := env.MustParse(`{{name}}`)
+	tpl.RegisterHelper("name", func() string { return "template" })
+
+	if output := tpl.MustExec(nil); output != "template" {
+		t.Errorf("Template helper must override environment helper, got: %q", output)
+	}
+
+	if output := tpl.Clone().MustExec(nil); output != "template" {
+		t.Errorf("Unexpected output with cloned template: %q", output)
+	}
+}
+
+func TestEnvironmentOptions(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.SetStrict(true)
+	env.SetEscaper(EscapeURLQuery)
+
+	tpl := env.MustParse(`{{q}}`)
+
+	if _, err := tpl.Exec(nil); err == nil {
+		t.Errorf("Error expected in strict mode")
+	}
+
+	if output := tpl.MustExec(map[string]string{"q": "a b"}); output != "a+b" {
+		t.Errorf("Unexpected output with environment escaper: %q", output)
+	}
+
+	// options are only applied to templates parsed afterwards
+	env.SetStrict(false)
+
+	if _, err := tpl.Exec(nil); err == nil {
+		t.Errorf("Error expected in strict mode")
+	}
+
+	if output := env.MustParse(`{{q}}`).MustExec(nil); output != "" {
+		t.Errorf("Unexpected output without strict mode: %q", output)
+	}
+}
+
+func TestEnvironmentPartialsParseOptions(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.SetKnownHelpersOnly(true)
+	env.RegisterHelper("upper", strings.ToUpper)
+	env.RegisterDecorator("partial", partialDecorator)
+	env.RegisterPartials(map[string]string{
+		"valid": "{{upper name}}",
+		"typo":  "{{uper name}}",
+	})
+
+	// environment partials are parsed with the options and helpers of their environment
+	tpl := env.MustParse(`{{> valid}}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if output := tpl.MustExec(map[string]string{"name": "foo"}); output != "FOO" {
+				t.Errorf("Unexpected output with environment partial: %q", output)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var perr *ParseError
+
+	_, err := env.MustParse(`{{> typo}}`).Exec(nil)
+	if !errors.As(err, &perr) || (perr.Name != "typo") || (perr.Message != "Unknown helper: uper") {
+		t.Errorf("Expected an unknown helper error for environment partial, got: %v", err)
+	}
+
+	// so are partials registered by decorators
+	_, err = env.MustParse(`{{* partial "dyn" source="{{uper name}}"}}{{> dyn}}`).Exec(nil)
+	if !errors.As(err, &perr) || (perr.Name != "dyn") || (perr.Message != "Unknown helper: uper") {
+		t.Errorf("Expected an unknown helper error for decorator partial, got: %v", err)
+	}
+}
+
+func TestEnvironmentLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := logrus.New()
+	logger.Out = &buf
+
+	env := NewEnvironment()
+	env.SetLogger(logrus.NewEntry(logger).WithField("lib", "test"))
+
+	if output := env.MustParse(`{{log "hello"}}`).MustExec(nil); output != "" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if !strings.Contains(buf.String(), "hello") || !strings.Contains(buf.String(), "lib=test") {
+		t.Errorf("Unexpected log output: %q", buf.String())
+	}
+}
+
+func TestEnvironmentLoader(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.RegisterHelper("upper", strings.ToUpper)
+
+	l, err := env.NewLoader(fstest.MapFS{"page.hbs": {Data: []byte(`{{upper name}}`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output := loaderExec(t, l, "page", map[string]string{"name": "jo"}); output != "JO" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
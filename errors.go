@@ -0,0 +1,121 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is the error returned when a template or a partial can not be parsed.
+type ParseError struct {
+	// template name: the file path for templates parsed from files, the partial name for partials, empty otherwise
+	Name string
+
+	// 1-based line and column of the error in template source, column is counted in runes
+	Line   int
+	Column int
+
+	// error message, without position
+	Message string
+
+	// source line of the error, followed by a line with a caret under the error column
+	Excerpt string
+
+	// names of the partials being rendered, outermost first, when a partial failed to parse during evaluation
+	Partials []string
+}
+
+// Error returns the error message, prefixed with the error location
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("Parse error at %s:\n%s", location(err.Name, err.Line, err.Column), err.Message)
+}
+
+// ExecError is the error returned when a template evaluation fails.
+//
+// Errors returned by the output writer, evaluation context errors and errors panicked by helpers are available with
+// errors.Is() and errors.As().
+type ExecError struct {
+	// name of the template containing the node being evaluated, see ParseError
+	Name string
+
+	// 1-based line and column of the node being evaluated in template source, column is counted in runes
+	Line   int
+	Column int
+
+	// source line of the node being evaluated, followed by a line with a caret under its column
+	Excerpt string
+
+	// names of the partials being rendered, outermost first
+	Partials []string
+
+	// underlying error
+	Err error
+}
+
+// Error returns the error message, prefixed with the location of the node being evaluated
+func (err *ExecError) Error() string {
+	if err.Line == 0 {
+		return fmt.Sprintf("evaluation error: %s", err.Err)
+	}
+
+	return fmt.Sprintf("evaluation error at %s: %s", location(err.Name, err.Line, err.Column), err.Err)
+}
+
+// Unwrap returns the underlying error
+func (err *ExecError) Unwrap() error {
+	return err.Err
+}
+
+// location returns the name:line:column representation of an error position, the name being omitted if empty
+func location(name string, line int, column int) string {
+	if name == "" {
+		return fmt.Sprintf("%d:%d", line, column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", name, line, column)
+}
+
+// newParseError instanciates a parse error at given byte position of template source
+func (tpl *Template) newParseError(pos int, msg string) *ParseError {
+	result := &ParseError{
+		Name:    tpl.name,
+		Message: msg,
+	}
+
+	result.Line, result.Column, result.Excerpt = sourcePosition(tpl.source, pos)
+
+	return result
+}
+
+// sourcePosition returns the line and column of given byte position in source, and an excerpt of that line with a
+// caret under that column
+func sourcePosition(source string, pos int) (int, int, string) {
+	if pos > len(source) {
+		pos = len(source)
+	}
+
+	start := strings.LastIndexByte(source[:pos], '\n') + 1
+
+	end := strings.IndexByte(source[pos:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += pos
+	}
+
+	line := strings.Count(source[:start], "\n") + 1
+	column := utf8.RuneCountInString(source[start:pos]) + 1
+
+	// keep tabs so that the caret is aligned with the source line
+	var caret strings.Builder
+	for _, r := range source[start:pos] {
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+
+	return line, column, strings.TrimRight(source[start:end], "\r") + "\n" + caret.String()
+}
@@ -0,0 +1,171 @@
+package raymond
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("<ul>\n  <li>{{#each items}}{{name}</li>\n</ul>")
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a ParseError, got: %v", err)
+	}
+
+	if (perr.Line != 2) || (perr.Column != 28) {
+		t.Errorf("Unexpected parse error position: %d:%d", perr.Line, perr.Column)
+	}
+
+	if expected := "  <li>{{#each items}}{{name}</li>\n                           ^"; perr.Excerpt != expected {
+		t.Errorf("Unexpected parse error excerpt\nexpected\n\t%q\ngot\n\t%q", expected, perr.Excerpt)
+	}
+
+	if expected := "Parse error at 2:28:\n" + perr.Message; err.Error() != expected {
+		t.Errorf("Unexpected parse error message\nexpected\n\t%q\ngot\n\t%q", expected, err.Error())
+	}
+}
+
+func TestParseErrorName(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{"views/index.hbs": {Data: []byte("\t{{été}} {{/foo}}")}}
+
+	_, err := ParseFS(fsys, "views/index.hbs")
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a ParseError, got: %v", err)
+	}
+
+	if (perr.Name != "views/index.hbs") || (perr.Line != 1) || (perr.Column != 10) {
+		t.Errorf("Unexpected parse error location: %s:%d:%d", perr.Name, perr.Line, perr.Column)
+	}
+
+	if expected := "\t{{été}} {{/foo}}\n\t        ^"; perr.Excerpt != expected {
+		t.Errorf("Unexpected parse error excerpt\nexpected\n\t%q\ngot\n\t%q", expected, perr.Excerpt)
+	}
+
+	if expected := "Parse error at views/index.hbs:1:10:\n" + perr.Message; err.Error() != expected {
+		t.Errorf("Unexpected parse error message\nexpected\n\t%q\ngot\n\t%q", expected, err.Error())
+	}
+
+	// known helpers only mode
+	env := NewEnvironment()
+	env.SetKnownHelpersOnly(true)
+
+	_, err = env.Parse("{{#if ok}}\n  {{formatDate date}}{{/if}}")
+	if !errors.As(err, &perr) || (perr.Line != 2) || (perr.Column != 3) || (perr.Message != "Unknown helper: formatDate") {
+		t.Errorf("Unexpected error for an unknown helper: %#v", err)
+	}
+}
+
+func TestExecError(t *testing.T) {
+	t.Parallel()
+
+	errNotFound := errors.New("user not found")
+
+	tpl := MustParse("<h1>{{title}}</h1>\n{{> card user}}")
+	tpl.RegisterPartial("card", "{{#if .}}\n  {{> avatar}}\n{{/if}}")
+	tpl.RegisterPartial("avatar", "<img src=\"{{avatarURL id}}\">")
+	tpl.RegisterHelper("avatarURL", func(id string) string {
+		panic(errNotFound)
+	})
+
+	_, err := tpl.Exec(map[string]any{"user": map[string]string{"id": "1"}})
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("Expected helper error, got: %v", err)
+	}
+
+	var eerr *ExecError
+	if !errors.As(err, &eerr) {
+		t.Fatalf("Expected an ExecError, got: %v", err)
+	}
+
+	if (eerr.Name != "avatar") || (eerr.Line != 1) || (eerr.Column != 11) {
+		t.Errorf("Unexpected evaluation error location: %s:%d:%d", eerr.Name, eerr.Line, eerr.Column)
+	}
+
+	if expected := []string{"card", "avatar"}; !reflect.DeepEqual(eerr.Partials, expected) {
+		t.Errorf("Unexpected evaluation error partials: %q", eerr.Partials)
+	}
+
+	if expected := "<img src=\"{{avatarURL id}}\">\n          ^"; eerr.Excerpt != expected {
+		t.Errorf("Unexpected evaluation error excerpt\nexpected\n\t%q\ngot\n\t%q", expected, eerr.Excerpt)
+	}
+
+	if expected := "evaluation error at avatar:1:11: user not found"; err.Error() != expected {
+		t.Errorf("Unexpected evaluation error message\nexpected\n\t%q\ngot\n\t%q", expected, err.Error())
+	}
+}
+
+func TestExecErrorPartialBlock(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#> layout}}\n  {{foo 1 2}}\n{{/layout}}")
+	tpl.RegisterPartial("layout", "<div>{{> @partial-block}}</div>")
+	tpl.RegisterHelper("foo", func(a int) string { return "" })
+
+	_, err := tpl.Exec(nil)
+
+	var eerr *ExecError
+	if !errors.As(err, &eerr) {
+		t.Fatalf("Expected an ExecError, got: %v", err)
+	}
+
+	// the block content is located in the caller template
+	if (eerr.Name != "") || (eerr.Line != 2) || (eerr.Column != 3) {
+		t.Errorf("Unexpected evaluation error location: %s:%d:%d", eerr.Name, eerr.Line, eerr.Column)
+	}
+
+	if expected := []string{"layout", partialBlockName}; !reflect.DeepEqual(eerr.Partials, expected) {
+		t.Errorf("Unexpected evaluation error partials: %q", eerr.Partials)
+	}
+}
+
+func TestExecPartialParseError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#each items}}{{> item}}{{/each}}")
+	tpl.RegisterPartial("item", "<li>\n{{name}</li>")
+
+	_, err := tpl.Exec(map[string]any{"items": []string{"a"}})
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a ParseError, got: %v", err)
+	}
+
+	if (perr.Name != "item") || (perr.Line != 2) || !reflect.DeepEqual(perr.Partials, []string{"item"}) {
+		t.Errorf("Unexpected partial parse error location: %s:%d %q", perr.Name, perr.Line, perr.Partials)
+	}
+}
+
+func TestExecErrorHelperMissing(t *testing.T) {
+	t.Parallel()
+
+	errMissing := errors.New("missing helper")
+
+	tpl := MustParse("<h1>{{title}}</h1>\n<p>{{t \"greeting\"}}</p>")
+	tpl.RegisterHelper("helperMissing", func(options *Options) string {
+		panic(errMissing)
+	})
+
+	_, err := tpl.Exec(map[string]string{"title": "Home"})
+	if !errors.Is(err, errMissing) {
+		t.Fatalf("Expected helperMissing error, got: %v", err)
+	}
+
+	var eerr *ExecError
+	if !errors.As(err, &eerr) {
+		t.Fatalf("Expected an ExecError, got: %v", err)
+	}
+
+	if (eerr.Line != 2) || (eerr.Column != 4) {
+		t.Errorf("Unexpected evaluation error location: %d:%d", eerr.Line, eerr.Column)
+	}
+}
@@ -2,6 +2,9 @@ package raymond
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
 	"strings"
 )
 
@@ -16,7 +19,8 @@ import (
 //
 
 type writer interface {
-	WriteString(string) (int, error)
+	io.Writer
+	io.StringWriter
 }
 
 const escapedChars = `&'<>"`
@@ -63,3 +67,60 @@ func Escape(s string) string {
 	escape(&buf, s)
 	return buf.String()
 }
+
+// EscapeValue returns the string representation of given value, escaped with Escape() unless it is a SafeString.
+//
+// It is used by code generated with raymond-gen to output mustaches.
+func EscapeValue(value any) string {
+	if isSafeString(value) {
+		return Str(value)
+	}
+
+	return Escape(Str(value))
+}
+
+// Escaper is a function that escapes the result of a mustache before it is written to template output.
+//
+// Cf. Template.SetEscaper().
+type Escaper func(string) string
+
+// htmlReplacer replaces the same characters as handlebars.js escapeExpression() function
+var htmlReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&#x27;",
+	"`", "&#x60;",
+	"=", "&#x3D;",
+)
+
+// EscapeHTML escapes special HTML characters exactly like handlebars.js does.
+//
+// It differs from Escape() as it escapes the ` and = characters too, and as it escapes ' as &#x27;.
+func EscapeHTML(s string) string {
+	return htmlReplacer.Replace(s)
+}
+
+// NoEscape returns given string as is.
+//
+// Use it as a template escaper to disable escaping for a whole template, like the handlebars.js noEscape option.
+func NoEscape(s string) string {
+	return s
+}
+
+// EscapeJSON escapes given string so that it can be inserted between the double quotes of a JSON string.
+//
+// The <, > and & characters are escaped too, so the result can safely be embedded in HTML.
+func EscapeJSON(s string) string {
+	// marshaling a string never fails
+	b, _ := json.Marshal(s)
+
+	// remove surrounding double quotes
+	return string(b[1 : len(b)-1])
+}
+
+// EscapeURLQuery escapes given string so that it can be used as an URL query component.
+func EscapeURLQuery(s string) string {
+	return url.QueryEscape(s)
+}
@@ -1,6 +1,9 @@
 package raymond
 
-import "fmt"
+import (
+	"fmt"
+	"testing"
+)
 
 func ExampleEscape() {
 	tpl := MustParse("{{link url text}}")
@@ -18,3 +21,62 @@ func ExampleEscape() {
 	fmt.Print(result)
 	// Output: <a href='http://www.aymerick.com/'>This is a &lt;em&gt;cool&lt;/em&gt; website</a>
 }
+
+func TestEscapers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		escaper Escaper
+		input   string
+		output  string
+	}{
+		{"Escape", Escape, `<a href="x">'&'</a>`, `&lt;a href=&quot;x&quot;&gt;&apos;&amp;&apos;&lt;/a&gt;`},
+		{"EscapeHTML", EscapeHTML, "<a href=\"x\">'&'`</a>", "&lt;a href&#x3D;&quot;x&quot;&gt;&#x27;&amp;&#x27;&#x60;&lt;/a&gt;"},
+		{"NoEscape", NoEscape, `<a href="x">'&'</a>`, `<a href="x">'&'</a>`},
+		{"EscapeJSON", EscapeJSON, "say \"hi\"\n<b>\\", `say \"hi\"\n\u003cb\u003e\\`},
+		{"EscapeURLQuery", EscapeURLQuery, "a b&c=d/é", "a+b%26c%3Dd%2F%C3%A9"},
+	}
+
+	for _, test := range tests {
+		if output := test.escaper(test.input); output != test.output {
+			t.Errorf("%s failed\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestTemplateEscaper(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{a}} {{{a}}} {{safe}} {{> p}}`)
+	tpl.RegisterPartial("p", "{{a}}")
+	tpl.RegisterHelper("safe", func(options *Options) SafeString {
+		return SafeString("[" + options.Escape("a=b") + "]")
+	})
+
+	ctx := map[string]string{"a": "x=<y>"}
+
+	tpl.SetEscaper(NoEscape)
+	if output, expected := tpl.MustExec(ctx), "x=<y> x=<y> [a=b] x=<y>"; output != expected {
+		t.Errorf("Unexpected output with NoEscape escaper: %q, expected %q", output, expected)
+	}
+
+	tpl.SetEscaper(EscapeHTML)
+	if output, expected := tpl.MustExec(ctx), "x&#x3D;&lt;y&gt; x=<y> [a&#x3D;b] x&#x3D;&lt;y&gt;"; output != expected {
+		t.Errorf("Unexpected output with EscapeHTML escaper: %q, expected %q", output, expected)
+	}
+
+	tpl.SetEscaper(nil)
+	if output, expected := tpl.MustExec(ctx), "x=&lt;y&gt; x=<y> [a=b] x=&lt;y&gt;"; output != expected {
+		t.Errorf("Unexpected output with default escaper: %q, expected %q", output, expected)
+	}
+}
+
+func ExampleTemplate_SetEscaper() {
+	tpl := MustParse(`{"name": "{{name}}"}`)
+	tpl.SetEscaper(EscapeJSON)
+
+	result := tpl.MustExec(map[string]string{"name": `John "Doe"`})
+	fmt.Print(result)
+	// Output: {"name": "John \"Doe\""}
+}
@@ -2,10 +2,14 @@ package raymond
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/yoinkai/raymond/v2/ast"
 )
@@ -42,12 +46,106 @@ type evalVisitor struct {
 
 	// used for info on panic
 	curNode ast.Node
+
+	// current output
+	out writer
+
+	// evaluation context, used for cancellation
+	execCtx context.Context
+	done    <-chan struct{}
+
+	// evaluation options
+	opts execOptions
+
+	// mustaches escapers of current template, when contextual HTML escaping is enabled
+	htmlEscapers htmlEscapers
+
+	// compiled programs of current template
+	programs compiledPrograms
+
+	// template whose nodes are evaluated, to locate errors
+	srcTpl *Template
+
+	// names of partials being rendered
+	partialNames []string
+
+	// inline partials stack
+	inlinePartials []map[string]*partial
+
+	// partial blocks stack
+	partialBlocks []*partialBlock
+
+	// helpers registered by decorators stack
+	decoratedHelpers []map[string]reflect.Value
+
+	// layout frames stack, for the extend, block and content helpers
+	layouts []*layoutFrame
+}
+
+// partialBlock is the content of a partial block statement, rendered by the partial with {{> @partial-block}}
+type partialBlock struct {
+	program *ast.Program
+
+	// caller template, compiled programs and mustaches escapers
+	tpl          *Template
+	programs     compiledPrograms
+	htmlEscapers htmlEscapers
+}
+
+// hashContext is the context of a partial called with hash arguments and a context that is not a map: fields are
+// looked up in the hash, then in the context
+type hashContext struct {
+	hash map[string]any
+	ctx  reflect.Value
 }
 
-// NewEvalVisitor instanciate a new evaluation visitor with given context and initial private data frame
+var hashContextType = reflect.TypeOf(hashContext{})
+
+// merged returns the context values merged with the hash values, or the context if it is neither a struct nor a map
+//
+// Struct fields are keyed by their handlebars tag, or by their name with a lower case first letter: firstName.
+func (hctx hashContext) merged() reflect.Value {
+	ctx := hctx.ctx
+
+	result := make(map[string]any)
+
+	switch ctx.Kind() {
+	case reflect.Struct:
+		for i := 0; i < ctx.NumField(); i++ {
+			tField := ctx.Type().Field(i)
+			if tField.PkgPath != "" {
+				// unexported field
+				continue
+			}
+
+			key := tField.Tag.Get("handlebars")
+			if key == "" {
+				r, size := utf8.DecodeRuneInString(tField.Name)
+				key = string(unicode.ToLower(r)) + tField.Name[size:]
+			}
+
+			result[key] = ctx.Field(i).Interface()
+		}
+	case reflect.Map:
+		iter := ctx.MapRange()
+		for iter.Next() {
+			result[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+	default:
+		return ctx
+	}
+
+	for key, val := range hctx.hash {
+		result[key] = val
+	}
+
+	return reflect.ValueOf(result)
+}
+
+// NewEvalVisitor instanciate a new evaluation visitor with given evaluation context, context, initial private data frame and output
 //
 // If privData is nil, then a default data frame is created
-func newEvalVisitor(tpl *Template, ctx any, privData *DataFrame) *evalVisitor {
+func newEvalVisitor(execCtx context.Context, tpl *Template, ctx any, privData *DataFrame, out writer) *evalVisitor {
 	frame := privData
 	if frame == nil {
 		frame = NewDataFrame()
@@ -57,7 +155,12 @@ func newEvalVisitor(tpl *Template, ctx any, privData *DataFrame) *evalVisitor {
 		tpl:       tpl,
 		ctx:       []reflect.Value{reflect.ValueOf(ctx)},
 		dataFrame: frame,
-		exprFunc:  make(map[*ast.Expression]bool),
+		out:       out,
+		execCtx:   execCtx,
+		done:      execCtx.Done(),
+		opts:      tpl.execOptions(),
+		programs:  tpl.programs,
+		srcTpl:    tpl,
 	}
 }
 
@@ -215,13 +318,264 @@ func (v *evalVisitor) curExpr() *ast.Expression {
 	return v.exprs[len(v.exprs)-1]
 }
 
+//
+// Inline partials stack
+//
+
+// pushInlinePartials pushes inline partials defined by a program to stack
+func (v *evalVisitor) pushInlinePartials(partials map[string]*partial) {
+	v.inlinePartials = append(v.inlinePartials, partials)
+}
+
+// popInlinePartials pops last inline partials from stack
+func (v *evalVisitor) popInlinePartials() {
+	if len(v.inlinePartials) == 0 {
+		return
+	}
+
+	v.inlinePartials = v.inlinePartials[:len(v.inlinePartials)-1]
+}
+
+//
+// Partial blocks stack
+//
+
+// pushPartialBlock pushes a partial block to stack
+func (v *evalVisitor) pushPartialBlock(block *partialBlock) {
+	v.partialBlocks = append(v.partialBlocks, block)
+}
+
+// popPartialBlock pops last partial block from stack
+func (v *evalVisitor) popPartialBlock() *partialBlock {
+	if len(v.partialBlocks) == 0 {
+		return nil
+	}
+
+	var result *partialBlock
+	result, v.partialBlocks = v.partialBlocks[len(v.partialBlocks)-1], v.partialBlocks[:len(v.partialBlocks)-1]
+
+	return result
+}
+
+//
+// Decorators
+//
+
+// decorate calls given decorators before the evaluation of the program containing them, and returns a function that
+// restores the helpers, partials and data frame they changed
+func (v *evalVisitor) decorate(decorators []ast.Node) func() {
+	prevFrame := v.dataFrame
+	v.setDataFrame(prevFrame.Copy())
+
+	helpers := make(map[string]reflect.Value)
+	partials := make(map[string]*partial)
+
+	for _, node := range decorators {
+		v.callDecorator(node, helpers, partials)
+	}
+
+	v.decoratedHelpers = append(v.decoratedHelpers, helpers)
+	v.pushInlinePartials(partials)
+
+	return func() {
+		v.popInlinePartials()
+		v.decoratedHelpers = v.decoratedHelpers[:len(v.decoratedHelpers)-1]
+		v.setDataFrame(prevFrame)
+	}
+}
+
+// callDecorator calls given decorator, and collects the helpers and partials it registers
+func (v *evalVisitor) callDecorator(node ast.Node, helpers map[string]reflect.Value, partials map[string]*partial) {
+	v.at(node)
+
+	var expr *ast.Expression
+	var block *ast.BlockStatement
+
+	switch n := node.(type) {
+	case *ast.Decorator:
+		expr = n.Expression
+	case *ast.DecoratorBlock:
+		expr = n.Expression
+		block = &ast.BlockStatement{Expression: n.Expression, Program: n.Program}
+	}
+
+	name := expr.HelperName()
+	if name == "inline" {
+		v.errorf("Inline partial name must be a string")
+	}
+
+	decorator := v.findDecorator(name)
+	if decorator == nil {
+		v.errorf("Decorator not found: %s", expr.Canonical())
+	}
+
+	if block != nil {
+		// block content is evaluated with options.Fn()
+		v.pushBlock(block)
+		defer v.popBlock()
+	}
+
+	options := newDecoratorOptions(v.helperOptions(expr))
+
+	func() {
+		defer v.errLocate(node)
+
+		decorator(options)
+	}()
+
+	for name, helper := range options.helpers {
+		helpers[name] = helper
+	}
+
+	for name, p := range options.partials {
+		partials[name] = p
+	}
+}
+
+// findDecorator finds given decorator
+func (v *evalVisitor) findDecorator(name string) Decorator {
+	// check template decorators
+	if d := v.tpl.findDecorator(name); d != nil {
+		return d
+	}
+
+	// check environment decorators
+	return v.tpl.env.findDecorator(name)
+}
+
+// findDecoratedHelper finds given helper in those registered by decorators, innermost first
+func (v *evalVisitor) findDecoratedHelper(name string) reflect.Value {
+	for i := len(v.decoratedHelpers) - 1; i >= 0; i-- {
+		if h, ok := v.decoratedHelpers[i][name]; ok {
+			return h
+		}
+	}
+
+	return zero
+}
+
+//
+// Output
+//
+
+// write writes given string to current output
+//
+// A write error aborts the evaluation.
+func (v *evalVisitor) write(str string) {
+	if str == "" {
+		return
+	}
+
+	if _, err := v.out.WriteString(str); err != nil {
+		v.errPanic(err)
+	}
+}
+
+// capture evaluates given function with output redirected to a buffer, and returns that buffer content
+func (v *evalVisitor) capture(fn func()) string {
+	out := v.out
+	buf := new(bytes.Buffer)
+
+	v.out = buf
+	defer func() { v.out = out }()
+
+	fn()
+
+	return buf.String()
+}
+
+// escape escapes given string with template escaper
+func (v *evalVisitor) escape(str string) string {
+	if v.opts.escaper != nil {
+		return v.opts.escaper(str)
+	}
+
+	return Escape(str)
+}
+
+//
+// Cancellation
+//
+
+// checkDone aborts the evaluation if evaluation context was cancelled or if its deadline passed
+func (v *evalVisitor) checkDone() {
+	select {
+	case <-v.done:
+		v.errPanic(v.execCtx.Err())
+	default:
+	}
+}
+
 //
 // Error functions
 //
 
-// errPanic panics
+// errPanic panics with given error located at current node
 func (v *evalVisitor) errPanic(err error) {
-	panic(fmt.Errorf("evaluation error: %s\nCurrent node:\n\t%s", err, v.curNode))
+	panic(v.locateError(err))
+}
+
+// errLocate recovers a panic of a helper or decorator called at given node, and panics again with the error located
+// at that node
+func (v *evalVisitor) errLocate(node ast.Node) {
+	e := recover()
+	if e == nil {
+		return
+	}
+
+	switch err := e.(type) {
+	case runtime.Error, *ParseError, *ExecError:
+	case error:
+		v.at(node)
+		e = v.locateError(err)
+	}
+
+	panic(e)
+}
+
+// errRecover recovers evaluation panic, and locates errors that were not panicked by errPanic
+func (v *evalVisitor) errRecover(errp *error) {
+	e := recover()
+	if e == nil {
+		return
+	}
+
+	switch err := e.(type) {
+	case runtime.Error:
+		panic(e)
+	case *ParseError:
+		*errp = err
+	case *ExecError:
+		*errp = err
+	case error:
+		*errp = v.locateError(err)
+	default:
+		panic(e)
+	}
+}
+
+// locateError returns given error with the current template, node position and partials
+//
+// A partial parse error is returned with partials being rendered, other errors are wrapped in an ExecError.
+func (v *evalVisitor) locateError(err error) error {
+	partials := append([]string(nil), v.partialNames...)
+
+	if perr, ok := err.(*ParseError); ok {
+		perr.Partials = partials
+		return perr
+	}
+
+	result := &ExecError{
+		Partials: partials,
+		Err:      err,
+	}
+
+	if (v.curNode != nil) && (v.srcTpl != nil) {
+		result.Name = v.srcTpl.name
+		result.Line, result.Column, result.Excerpt = sourcePosition(v.srcTpl.source, v.curNode.Location().Pos)
+	}
+
+	return result
 }
 
 // errorf panics with a custom message
@@ -233,8 +587,15 @@ func (v *evalVisitor) errorf(format string, args ...any) {
 // Evaluation
 //
 
-// evalProgram eEvaluates program with given context and returns string result
+// evalProgram evaluates program with given context and returns string result
 func (v *evalVisitor) evalProgram(program *ast.Program, ctx any, data *DataFrame, key any) string {
+	return v.capture(func() {
+		v.writeProgram(program, ctx, data, key)
+	})
+}
+
+// writeProgram evaluates program with given context and writes result to current output
+func (v *evalVisitor) writeProgram(program *ast.Program, ctx any, data *DataFrame, key any) {
 	blockParams := make(map[string]any)
 
 	// compute block params
@@ -261,7 +622,7 @@ func (v *evalVisitor) evalProgram(program *ast.Program, ctx any, data *DataFrame
 	}
 
 	// evaluate program
-	result, _ := program.Accept(v).(string)
+	program.Accept(v)
 
 	// pop contexts
 	if data != nil {
@@ -275,13 +636,13 @@ func (v *evalVisitor) evalProgram(program *ast.Program, ctx any, data *DataFrame
 	if len(blockParams) > 0 {
 		v.popBlockParams()
 	}
-
-	return result
 }
 
-// evalPath evaluates all path parts with given context
-func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool) (reflect.Value, bool) {
-	partResolved := false
+// evalPath evaluates all path parts with given context, and returns the number of parts that were resolved
+func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool) (reflect.Value, int) {
+	if len(parts) == 0 {
+		return thisContext(ctx), 0
+	}
 
 	for i := 0; i < len(parts); i++ {
 		part := parts[i]
@@ -293,14 +654,11 @@ func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool)
 
 		ctx = v.evalField(ctx, part, exprRoot)
 		if !ctx.IsValid() {
-			break
+			return ctx, i
 		}
-
-		// we resolved at least one part of path
-		partResolved = true
 	}
 
-	return ctx, partResolved
+	return ctx, len(parts)
 }
 
 // evalField evaluates field with given context
@@ -312,6 +670,15 @@ func (v *evalVisitor) evalField(ctx reflect.Value, fieldName string, exprRoot bo
 		return result
 	}
 
+	if ctx.Type() == hashContextType {
+		hctx := ctx.Interface().(hashContext)
+		if val, ok := hctx.hash[fieldName]; ok {
+			return reflect.ValueOf(val)
+		}
+
+		return v.evalField(hctx.ctx, fieldName, exprRoot)
+	}
+
 	// check if this is a method call
 	result, isMeth := v.evalMethod(ctx, fieldName, exprRoot)
 	if !isMeth {
@@ -346,7 +713,7 @@ func (v *evalVisitor) evalField(ctx reflect.Value, fieldName string, exprRoot bo
 	// matches a param helper. This is to support mutations to user params such as
 	// 'foo.length'.
 	var helper paramHelperFunc
-	if helper = findParamHelper(fieldName); helper != nil && result == zero {
+	if helper = v.tpl.env.findParamHelper(fieldName); helper != nil && result == zero {
 		result = helper(ctx)
 	}
 
@@ -365,6 +732,10 @@ func (v *evalVisitor) evalMethod(ctx reflect.Value, name string, exprRoot bool)
 		ctx = ctx.Addr()
 	}
 
+	if ctx.NumMethod() == 0 {
+		return zero, false
+	}
+
 	method := ctx.MethodByName(name)
 	if !method.IsValid() {
 		// example: subject() => Subject()
@@ -387,8 +758,12 @@ func (v *evalVisitor) evalFieldFunc(name string, funcVal reflect.Value, exprRoot
 		// create function arg with all params/hash
 		expr := v.curExpr()
 		options = v.helperOptions(expr)
+		options.name = name
 
 		// ok, that expression was a function call
+		if v.exprFunc == nil {
+			v.exprFunc = make(map[*ast.Expression]bool)
+		}
 		v.exprFunc[expr] = true
 	} else {
 		// we are not at root of expression, so we are a parameter... and we don't like
@@ -431,6 +806,7 @@ func (v *evalVisitor) findBlockParam(node *ast.PathExpression) (string, any) {
 // evalPathExpression evaluates a path expression
 func (v *evalVisitor) evalPathExpression(node *ast.PathExpression, exprRoot bool) any {
 	var result any
+	var resolved int
 
 	if name, value := v.findBlockParam(node); value != nil {
 		// block parameter value
@@ -447,14 +823,14 @@ func (v *evalVisitor) evalPathExpression(node *ast.PathExpression, exprRoot bool
 		newCtx := map[string]any{name: value}
 
 		v.pushCtx(reflect.ValueOf(newCtx))
-		result = v.evalCtxPathExpression(node, exprRoot)
+		result, resolved = v.evalCtxPathExpression(node, exprRoot)
 		v.popCtx()
 	} else {
 		ctxTried := false
 
 		if node.IsDataRoot() {
 			// context path
-			result = v.evalCtxPathExpression(node, exprRoot)
+			result, resolved = v.evalCtxPathExpression(node, exprRoot)
 
 			ctxTried = true
 		}
@@ -464,77 +840,111 @@ func (v *evalVisitor) evalPathExpression(node *ast.PathExpression, exprRoot bool
 			// so let's try with private data
 
 			// private data
-			result = v.evalDataPathExpression(node, exprRoot)
+			var dataResolved int
+
+			result, dataResolved = v.evalDataPathExpression(node, exprRoot)
+			if dataResolved > resolved {
+				resolved = dataResolved
+			}
 		}
 
 		if (result == nil) && !ctxTried {
 			// context path
-			result = v.evalCtxPathExpression(node, exprRoot)
+			result, resolved = v.evalCtxPathExpression(node, exprRoot)
 		}
 	}
 
+	if v.opts.strict || v.opts.assumeObjects {
+		v.checkPathResolved(node, resolved, exprRoot)
+	}
+
 	return result
 }
 
-// evalDataPathExpression evaluates a private data path expression
-func (v *evalVisitor) evalDataPathExpression(node *ast.PathExpression, exprRoot bool) any {
+// checkPathResolved panics if given path expression was not resolved, according to strict and assumeObjects options
+//
+// Any missing intermediate part of a path is an error, and in strict mode, a missing last part is an error too if the path is at the root of an expression.
+func (v *evalVisitor) checkPathResolved(node *ast.PathExpression, resolved int, exprRoot bool) {
+	nb := len(node.Parts)
+	if resolved >= nb {
+		return
+	}
+
+	if resolved < nb-1 {
+		v.errorf("%q not defined on line %d: %q is not defined", node.Original, node.Line, strings.Join(node.Parts[:resolved+1], "."))
+	}
+
+	if v.opts.strict && exprRoot {
+		v.errorf("%q not defined on line %d", node.Original, node.Line)
+	}
+}
+
+// evalDataPathExpression evaluates a private data path expression, and returns the number of path parts that were resolved
+func (v *evalVisitor) evalDataPathExpression(node *ast.PathExpression, exprRoot bool) (any, int) {
 	// find data frame
 	frame := v.dataFrame
 	for i := node.Depth; i > 0; i-- {
 		if frame.parent == nil {
-			return nil
+			return nil, 0
 		}
 		frame = frame.parent
 	}
 
 	// resolve data
 	// @note Can be changed to v.evalCtx() as context can't be an array
-	result, _ := v.evalCtxPath(reflect.ValueOf(frame.data), node.Parts, exprRoot)
-	return result
+	return v.evalCtxPath(reflect.ValueOf(frame.data), node.Parts, exprRoot)
 }
 
-// evalCtxPathExpression evaluates a context path expression
-func (v *evalVisitor) evalCtxPathExpression(node *ast.PathExpression, exprRoot bool) any {
+// evalCtxPathExpression evaluates a context path expression, and returns the number of path parts that were resolved
+func (v *evalVisitor) evalCtxPathExpression(node *ast.PathExpression, exprRoot bool) (any, int) {
 	v.at(node)
 
 	if node.IsDataRoot() {
 		// `@root` - remove the first part
 		parts := node.Parts[1:len(node.Parts)]
 
-		result, _ := v.evalCtxPath(v.rootCtx(), parts, exprRoot)
-		return result
+		result, resolved := v.evalCtxPath(v.rootCtx(), parts, exprRoot)
+		return result, resolved + 1
 	}
 
 	return v.evalDepthPath(node.Depth, node.Parts, exprRoot)
 }
 
 // evalDepthPath iterates on contexts, starting at given depth, until there is one that resolve given path parts
-func (v *evalVisitor) evalDepthPath(depth int, parts []string, exprRoot bool) any {
+//
+// It returns the number of path parts that were resolved. In compat mode, contexts are tried until the whole path is
+// resolved, otherwise the iteration stops at the first context that resolves the first part of the path.
+func (v *evalVisitor) evalDepthPath(depth int, parts []string, exprRoot bool) (any, int) {
 	var result any
-	partResolved := false
+	resolved := 0
 
 	ctx := v.ancestorCtx(depth)
 
-	for (result == nil) && ctx.IsValid() && (depth <= len(v.ctx) && !partResolved) {
+	// As soon as we find the first part of a path, we must not try to resolve with parent context if result is finally `nil`
+	// Reference: "Dotted Names - Context Precedence" mustache test
+	for (result == nil) && ctx.IsValid() && (depth <= len(v.ctx)) && ((resolved == 0) || v.opts.compat) {
 		// try with context
-		result, partResolved = v.evalCtxPath(ctx, parts, exprRoot)
+		var ctxResolved int
+
+		result, ctxResolved = v.evalCtxPath(ctx, parts, exprRoot)
+		if (result != nil) || (ctxResolved > resolved) {
+			resolved = ctxResolved
+		}
 
-		// As soon as we find the first part of a path, we must not try to resolve with parent context if result is finally `nil`
-		// Reference: "Dotted Names - Context Precedence" mustache test
-		if !partResolved && (result == nil) {
+		if result == nil {
 			// try with previous context
 			depth++
 			ctx = v.ancestorCtx(depth)
 		}
 	}
 
-	return result
+	return result, resolved
 }
 
-// evalCtxPath evaluates path with given context
-func (v *evalVisitor) evalCtxPath(ctx reflect.Value, parts []string, exprRoot bool) (any, bool) {
+// evalCtxPath evaluates path with given context, and returns the number of path parts that were resolved
+func (v *evalVisitor) evalCtxPath(ctx reflect.Value, parts []string, exprRoot bool) (any, int) {
 	var result any
-	partResolved := false
+	resolved := 0
 
 	switch ctx.Kind() {
 	case reflect.Array, reflect.Slice:
@@ -549,17 +959,18 @@ func (v *evalVisitor) evalCtxPath(ctx reflect.Value, parts []string, exprRoot bo
 		}
 
 		result = results
+		resolved = len(parts)
 	default:
 		// NOT array context
 		var value reflect.Value
 
-		value, partResolved = v.evalPath(ctx, parts, exprRoot)
+		value, resolved = v.evalPath(ctx, parts, exprRoot)
 		if value.IsValid() {
 			result = value.Interface()
 		}
 	}
 
-	return result, partResolved
+	return result, resolved
 }
 
 //
@@ -576,13 +987,18 @@ func (v *evalVisitor) isHelperCall(node *ast.Expression) bool {
 
 // findHelper finds given helper
 func (v *evalVisitor) findHelper(name string) reflect.Value {
+	// check helpers registered by decorators
+	if h := v.findDecoratedHelper(name); h != zero {
+		return h
+	}
+
 	// check template helpers
 	if h := v.tpl.findHelper(name); h != zero {
 		return h
 	}
 
-	// check global helpers
-	return findHelper(name)
+	// check environment helpers
+	return v.tpl.env.findHelper(name)
 }
 
 // callFunc calls function with given options
@@ -660,14 +1076,28 @@ func (v *evalVisitor) callFunc(name string, funcVal reflect.Value, options *Opti
 		args = append(args, reflect.ValueOf(options))
 	}
 
+	defer v.errLocate(v.helperNode(options))
+
 	result := funcVal.Call(args)
 
 	return result[0]
 }
 
+// helperNode returns the node where errors of a helper called with given options are located: the helper call
+func (v *evalVisitor) helperNode(options *Options) ast.Node {
+	if options.expr != nil {
+		return options.expr
+	}
+
+	return v.curNode
+}
+
 // callHelper invoqs helper function for given expression node
 func (v *evalVisitor) callHelper(name string, helper reflect.Value, node *ast.Expression) any {
-	result := v.callFunc(name, helper, v.helperOptions(node))
+	options := v.helperOptions(node)
+	options.name = name
+
+	result := v.callFunc(name, helper, options)
 	if !result.IsValid() {
 		return nil
 	}
@@ -676,21 +1106,85 @@ func (v *evalVisitor) callHelper(name string, helper reflect.Value, node *ast.Ex
 	return result.Interface()
 }
 
+// findHelperMissing returns the helperMissing helper if it must be called for given unresolved expression
+//
+// That is the case for a path with params or hash, or for a simple identifier. Blocks without params nor hash are
+// handled by blockHelperMissing instead.
+func (v *evalVisitor) findHelperMissing(node *ast.Expression) reflect.Value {
+	if (node.FieldPath() == nil) || v.wasFuncCall(node) {
+		return zero
+	}
+
+	if (len(node.Params) == 0) && (node.Hash == nil) {
+		if node.HelperName() == "" {
+			return zero
+		}
+
+		if block := v.curBlock(); (block != nil) && (block.Expression == node) {
+			return zero
+		}
+	}
+
+	return v.findHelper(helperMissingName)
+}
+
+// callMissingHelper calls the helperMissing or blockHelperMissing helper
+//
+// A helper that only expects an Options argument gets params and hash through it, whatever their number.
+func (v *evalVisitor) callMissingHelper(name string, helper reflect.Value, options *Options) any {
+	var result reflect.Value
+
+	if funcType := helper.Type(); (funcType.NumIn() == 1) && reflect.TypeOf(options).AssignableTo(funcType.In(0)) {
+		defer v.errLocate(v.helperNode(options))
+
+		result = helper.Call([]reflect.Value{reflect.ValueOf(options)})[0]
+	} else {
+		result = v.callFunc(name, helper, options)
+	}
+
+	if !result.IsValid() {
+		return nil
+	}
+
+	return result.Interface()
+}
+
+// evalParam evaluates a helper parameter or hash value
+//
+// In stringParams mode, a path expression evaluates to the path itself.
+func (v *evalVisitor) evalParam(node ast.Node) any {
+	if path, ok := node.(*ast.PathExpression); ok && v.opts.stringParams {
+		return path.Original
+	}
+
+	return node.Accept(v)
+}
+
 // helperOptions computes helper options argument from an expression
 func (v *evalVisitor) helperOptions(node *ast.Expression) *Options {
 	var params []any
 	var hash map[string]any
 
 	for _, paramNode := range node.Params {
-		param := paramNode.Accept(v)
+		param := v.evalParam(paramNode)
 		params = append(params, param)
 	}
 
 	if node.Hash != nil {
-		hash, _ = node.Hash.Accept(v).(map[string]any)
+		v.at(node.Hash)
+
+		hash = make(map[string]any)
+		for _, pair := range node.Hash.Pairs {
+			if value := v.evalParam(pair.Val); value != nil {
+				hash[pair.Key] = value
+			}
+		}
 	}
 
-	return newOptions(v, params, hash)
+	result := newOptions(v, params, hash)
+	result.expr = node
+
+	return result
 }
 
 //
@@ -699,62 +1193,196 @@ func (v *evalVisitor) helperOptions(node *ast.Expression) *Options {
 
 // findPartial finds given partial
 func (v *evalVisitor) findPartial(name string) *partial {
+	// check inline partials, innermost first
+	for i := len(v.inlinePartials) - 1; i >= 0; i-- {
+		if p := v.inlinePartials[i][name]; p != nil {
+			return p
+		}
+	}
+
 	// check template partials
 	if p := v.tpl.findPartial(name); p != nil {
 		return p
 	}
 
-	// check global partials
-	return findPartial(name)
+	// check environment partials
+	return v.tpl.env.findPartial(name)
 }
 
 // partialContext computes partial context
+//
+// Hash arguments extend the context argument, or the current context if there is none.
 func (v *evalVisitor) partialContext(node *ast.PartialStatement) reflect.Value {
 	if nb := len(node.Params); nb > 1 {
 		v.errorf("Unsupported number of partial arguments: %d", nb)
 	}
 
-	if (len(node.Params) > 0) && (node.Hash != nil) {
-		v.errorf("Passing both context and named parameters to a partial is not allowed")
+	ctx := zero
+	if len(node.Params) == 1 {
+		ctx = reflect.ValueOf(node.Params[0].Accept(v))
+	} else if node.Hash != nil {
+		ctx = v.curCtx()
 	}
 
-	if len(node.Params) == 1 {
-		return reflect.ValueOf(node.Params[0].Accept(v))
+	if node.Hash == nil {
+		return ctx
 	}
 
-	if node.Hash != nil {
-		hash, _ := node.Hash.Accept(v).(map[string]any)
+	hash, _ := node.Hash.Accept(v).(map[string]any)
+
+	return extendContext(ctx, hash)
+}
+
+// thisContext returns given context as seen by `this` and helpers: a hashContext is merged with its hash
+func thisContext(ctx reflect.Value) reflect.Value {
+	if ctx.IsValid() && (ctx.Type() == hashContextType) {
+		return ctx.Interface().(hashContext).merged()
+	}
+
+	return ctx
+}
+
+// extendContext returns given context extended with given hash values
+func extendContext(ctx reflect.Value, hash map[string]any) reflect.Value {
+	ctx, isNil := indirect(ctx)
+	if isNil || !ctx.IsValid() {
 		return reflect.ValueOf(hash)
 	}
 
-	return zero
+	if (ctx.Kind() != reflect.Map) || (ctx.Type().Key().Kind() != reflect.String) {
+		return reflect.ValueOf(hashContext{hash: hash, ctx: ctx})
+	}
+
+	result := make(map[string]any, ctx.Len()+len(hash))
+
+	iter := ctx.MapRange()
+	for iter.Next() {
+		result[iter.Key().String()] = iter.Value().Interface()
+	}
+
+	for key, val := range hash {
+		result[key] = val
+	}
+
+	return reflect.ValueOf(result)
+}
+
+// evalPartial evaluates a partial and writes result to current output
+func (v *evalVisitor) evalPartial(p *partial, node *ast.PartialStatement) {
+	v.writePartial(p, v.partialContext(node), node.Indent)
 }
 
-// evalPartial evaluates a partial
-func (v *evalVisitor) evalPartial(p *partial, node *ast.PartialStatement) string {
+// writePartial evaluates a partial with given context, and writes result to current output indented with given indent
+func (v *evalVisitor) writePartial(p *partial, ctx reflect.Value, indent string) {
+	v.partialNames = append(v.partialNames, p.name)
+
+	defer func() { v.partialNames = v.partialNames[:len(v.partialNames)-1] }()
+
 	// get partial template
 	partialTpl, err := p.template()
 	if err != nil {
 		v.errPanic(err)
 	}
 
+	if (indent != "") && (v.opts.preventIndent || partialTpl.execOptions().preventIndent) {
+		// standalone partial indentation is output as is
+		v.write(indent)
+		indent = ""
+	}
+
+	// switch to partial mustaches escapers
+	if v.htmlEscapers != nil {
+		escapers, err := partialTpl.contextEscapers()
+		if err != nil {
+			v.errPanic(err)
+		}
+
+		prevEscapers := v.htmlEscapers
+		v.htmlEscapers = escapers
+
+		defer func() { v.htmlEscapers = prevEscapers }()
+	}
+
+	// switch to partial template and compiled programs
+	prevSrcTpl, prevPrograms := v.srcTpl, v.programs
+	v.srcTpl, v.programs = partialTpl, partialTpl.programs
+
+	defer func() { v.srcTpl, v.programs = prevSrcTpl, prevPrograms }()
+
 	// push partial context
-	ctx := v.partialContext(node)
 	if ctx.IsValid() {
 		v.pushCtx(ctx)
 	}
 
 	// evaluate partial template
-	result, _ := partialTpl.program.Accept(v).(string)
-
-	// ident partial
-	result = indentLines(result, node.Indent)
+	if indent == "" {
+		partialTpl.program.Accept(v)
+	} else {
+		// ident partial
+		v.write(indentLines(v.capture(func() { partialTpl.program.Accept(v) }), indent))
+	}
 
 	if ctx.IsValid() {
 		v.popCtx()
 	}
+}
 
-	return result
+// evalPartialFallback evaluates the content of a partial block whose partial is not found, and writes result to current output
+func (v *evalVisitor) evalPartialFallback(node *ast.PartialStatement) {
+	ctx := v.partialContext(node)
+	if ctx.IsValid() {
+		v.pushCtx(ctx)
+		defer v.popCtx()
+	}
+
+	node.Program.Accept(v)
+}
+
+// evalPartialBlock evaluates the content of current partial block, and writes result to current output
+func (v *evalVisitor) evalPartialBlock(node *ast.PartialStatement) {
+	// the partial block is not available to its own content, which may be rendered by an enclosing partial block
+	block := v.popPartialBlock()
+	if block == nil {
+		v.errorf("Partial not found: %s", partialBlockName)
+	}
+
+	defer v.pushPartialBlock(block)
+
+	v.partialNames = append(v.partialNames, partialBlockName)
+
+	defer func() { v.partialNames = v.partialNames[:len(v.partialNames)-1] }()
+
+	// switch back to caller template, compiled programs and mustaches escapers
+	prevSrcTpl, prevPrograms, prevEscapers := v.srcTpl, v.programs, v.htmlEscapers
+	v.srcTpl, v.programs, v.htmlEscapers = block.tpl, block.programs, block.htmlEscapers
+
+	defer func() { v.srcTpl, v.programs, v.htmlEscapers = prevSrcTpl, prevPrograms, prevEscapers }()
+
+	ctx := v.partialContext(node)
+	if ctx.IsValid() {
+		v.pushCtx(ctx)
+		defer v.popCtx()
+	}
+
+	switch {
+	case node.Indent == "":
+		block.program.Accept(v)
+	case v.opts.preventIndent:
+		// standalone partial indentation is output as is
+		v.write(node.Indent)
+		block.program.Accept(v)
+	default:
+		v.write(indentLines(v.capture(func() { block.program.Accept(v) }), node.Indent))
+	}
+}
+
+// programInlinePartials returns inline partials defined by given program
+func (v *evalVisitor) programInlinePartials(node *ast.Program) map[string]*partial {
+	if program, ok := v.programs[node]; ok {
+		return program.inlinePartials
+	}
+
+	return inlinePartials(node, v.srcTpl, v.programs)
 }
 
 // indentLines indents all lines of given string
@@ -798,17 +1426,43 @@ func (v *evalVisitor) wasFuncCall(node *ast.Expression) bool {
 func (v *evalVisitor) VisitProgram(node *ast.Program) any {
 	v.at(node)
 
-	buf := new(bytes.Buffer)
+	if program, ok := v.programs[node]; ok {
+		if program.inlinePartials != nil {
+			v.pushInlinePartials(program.inlinePartials)
+			defer v.popInlinePartials()
+		}
 
-	for _, n := range node.Body {
-		if str := Str(n.Accept(v)); str != "" {
-			if _, err := buf.Write([]byte(str)); err != nil {
-				v.errPanic(err)
-			}
+		if len(program.decorators) > 0 {
+			restore := v.decorate(program.decorators)
+			defer restore()
 		}
+
+		for _, stmt := range program.statements {
+			v.checkDone()
+
+			stmt(v)
+		}
+
+		return nil
 	}
 
-	return buf.String()
+	if partials := inlinePartials(node, v.srcTpl, v.programs); partials != nil {
+		v.pushInlinePartials(partials)
+		defer v.popInlinePartials()
+	}
+
+	if decorators := programDecorators(node); len(decorators) > 0 {
+		restore := v.decorate(decorators)
+		defer restore()
+	}
+
+	for _, n := range node.Body {
+		v.checkDone()
+
+		n.Accept(v)
+	}
+
+	return nil
 }
 
 // VisitMustache implements corresponding Visitor interface method
@@ -818,17 +1472,30 @@ func (v *evalVisitor) VisitMustache(node *ast.MustacheStatement) any {
 	// evaluate expression
 	expr := node.Expression.Accept(v)
 
+	v.writeMustache(node, expr)
+
+	return nil
+}
+
+// writeMustache writes the result of a mustache expression, escaped if necessary
+func (v *evalVisitor) writeMustache(node *ast.MustacheStatement, expr any) {
 	// check if this is a safe string
 	isSafe := isSafeString(expr)
 
 	// get string value
-	str := Str(expr)
-	if !isSafe && !node.Unescaped {
+	var str string
+	switch {
+	case isSafe || node.Unescaped:
+		str = Str(expr)
+	case v.htmlEscapers != nil:
+		// contextual HTML escaping
+		str = v.htmlEscapers.escape(node, expr)
+	default:
 		// escape html
-		str = Escape(str)
+		str = v.escape(Str(expr))
 	}
 
-	return str
+	v.write(str)
 }
 
 // VisitBlock implements corresponding Visitor interface method
@@ -837,47 +1504,60 @@ func (v *evalVisitor) VisitBlock(node *ast.BlockStatement) any {
 
 	v.pushBlock(node)
 
-	var result any
-
 	// evaluate expression
 	expr := node.Expression.Accept(v)
 
-	if v.isHelperCall(node.Expression) || v.wasFuncCall(node.Expression) {
+	v.writeBlockResult(node, expr, v.isHelperCall(node.Expression) || v.wasFuncCall(node.Expression))
+
+	v.popBlock()
+
+	return nil
+}
+
+// writeBlockResult evaluates a block according to the result of its expression, and writes result to current output
+func (v *evalVisitor) writeBlockResult(node *ast.BlockStatement, expr any, isCall bool) {
+	if isCall {
 		// it is the responsibility of the helper/function to evaluate block
-		result = expr
-	} else {
-		val := reflect.ValueOf(expr)
-
-		truth, _ := isTrueValue(val)
-		if truth {
-			if node.Program != nil {
-				switch val.Kind() {
-				case reflect.Array, reflect.Slice:
-					concat := ""
-
-					// Array context
-					for i := 0; i < val.Len(); i++ {
-						// Computes new private data frame
-						frame := v.dataFrame.newIterDataFrame(val.Len(), i, nil)
-
-						// Evaluate program
-						concat += v.evalProgram(node.Program, val.Index(i).Interface(), frame, i)
-					}
-
-					result = concat
-				default:
-					// NOT array
-					result = v.evalProgram(node.Program, expr, nil, nil)
-				}
-			}
-		} else if node.Inverse != nil {
-			result, _ = node.Inverse.Accept(v).(string)
+		v.write(Str(expr))
+		return
+	}
+
+	if (node.Program != nil) && (len(node.Expression.Params) == 0) && (node.Expression.Hash == nil) {
+		if helper := v.findHelper(blockHelperMissingName); helper != zero {
+			// block on a value, handled by blockHelperMissing
+			options := newOptions(v, []any{expr}, nil)
+			options.name = node.Expression.Canonical()
+
+			v.write(Str(v.callMissingHelper(blockHelperMissingName, helper, options)))
+			return
 		}
 	}
 
-	v.popBlock()
+	val := reflect.ValueOf(expr)
 
-	return result
+	truth, _ := isTrueValue(val)
+	if truth {
+		if node.Program != nil {
+			switch val.Kind() {
+			case reflect.Array, reflect.Slice:
+				// Array context
+				for i := 0; i < val.Len(); i++ {
+					v.checkDone()
+
+					// Computes new private data frame
+					frame := v.dataFrame.newIterDataFrame(val.Len(), i, nil)
+
+					// Evaluate program
+					v.writeProgram(node.Program, val.Index(i).Interface(), frame, i)
+				}
+			default:
+				// NOT array
+				v.writeProgram(node.Program, expr, nil, nil)
+			}
+		}
+	} else if node.Inverse != nil {
+		node.Inverse.Accept(v)
+	}
 }
 
 // VisitPartial implements corresponding Visitor interface method
@@ -896,12 +1576,62 @@ func (v *evalVisitor) VisitPartial(node *ast.PartialStatement) any {
 		v.errorf("Unexpected partial name: %q", node.Name)
 	}
 
+	if name == partialBlockName {
+		v.evalPartialBlock(node)
+		return nil
+	}
+
 	partial := v.findPartial(name)
+
+	if node.Program != nil {
+		// partial block
+		if partial == nil {
+			// render fallback content
+			v.evalPartialFallback(node)
+			return nil
+		}
+
+		v.pushPartialBlock(&partialBlock{
+			program:      node.Program,
+			tpl:          v.srcTpl,
+			programs:     v.programs,
+			htmlEscapers: v.htmlEscapers,
+		})
+		defer v.popPartialBlock()
+
+		// inline partials defined in partial block are available to called partial
+		if partials := v.programInlinePartials(node.Program); partials != nil {
+			v.pushInlinePartials(partials)
+			defer v.popInlinePartials()
+		}
+	}
+
 	if partial == nil {
 		v.errorf("Partial not found: %s", name)
 	}
 
-	return v.evalPartial(partial, node)
+	v.evalPartial(partial, node)
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+//
+// Decorators are called when entering the program containing them, so they output nothing.
+func (v *evalVisitor) VisitDecorator(node *ast.Decorator) any {
+	v.at(node)
+
+	return nil
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+//
+// Inline partials are defined and decorators are called when entering the program containing them, so they output
+// nothing.
+func (v *evalVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) any {
+	v.at(node)
+
+	return nil
 }
 
 // VisitContent implements corresponding Visitor interface method
@@ -909,7 +1639,9 @@ func (v *evalVisitor) VisitContent(node *ast.ContentStatement) any {
 	v.at(node)
 
 	// write content as is
-	return node.Value
+	v.write(node.Value)
+
+	return nil
 }
 
 // VisitComment implements corresponding Visitor interface method
@@ -917,7 +1649,7 @@ func (v *evalVisitor) VisitComment(node *ast.CommentStatement) any {
 	v.at(node)
 
 	// ignore comments
-	return ""
+	return nil
 }
 
 // Expressions
@@ -961,6 +1693,22 @@ func (v *evalVisitor) VisitExpression(node *ast.Expression) any {
 		}
 	}
 
+	if !done && (result == nil) {
+		// helper not found
+		if helper := v.findHelperMissing(node); helper != zero {
+			options := v.helperOptions(node)
+			options.name = node.Canonical()
+
+			result = v.callMissingHelper(helperMissingName, helper, options)
+
+			// that expression was a helper call
+			if v.exprFunc == nil {
+				v.exprFunc = make(map[*ast.Expression]bool)
+			}
+			v.exprFunc[node] = true
+		}
+	}
+
 	v.popExpr()
 
 	return result
@@ -1,6 +1,8 @@
 package raymond
 
 import (
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -74,6 +76,30 @@ var evalTests = []Test{
 		nil, nil, nil,
 		"C",
 	},
+	{
+		"partial with struct context and hash",
+		`{{> card user avatar=true}} {{#each users}}{{> card avatar=false}} {{/each}}`,
+		map[string]any{"user": Author{"Alan", "Johnson"}, "users": []*Author{{"Yehuda", "Katz"}}},
+		nil, nil,
+		map[string]string{"card": `{{firstName}}{{#if avatar}} [avatar]{{/if}} {{#with this}}{{lastName}}{{/with}}`},
+		"Alan [avatar] Johnson Yehuda Katz ",
+	},
+	{
+		"partial with struct context and hash merged in this",
+		`{{> card user avatar="[avatar]"}}`,
+		map[string]any{"user": Author{"Alan", "Johnson"}},
+		nil,
+		map[string]any{"keys": func(options *Options) string {
+			var keys []string
+			for key := range options.Ctx().(map[string]any) {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return strings.Join(keys, ",")
+		}},
+		map[string]string{"card": `{{lookup this "avatar"}} {{#each this}}{{#equal @key "avatar"}}{{this}}{{/equal}}{{/each}} {{keys}}`},
+		"[avatar] [avatar] avatar,firstName,lastName",
+	},
 
 	// @todo Test with a "../../path" (depth 2 path) while context is only depth 1
 }
@@ -274,3 +300,142 @@ func TestEvalMethodReturningFunc(t *testing.T) {
 		t.Errorf("Failed to evaluate struct method: %s", output)
 	}
 }
+
+type strictTest struct {
+	name          string
+	input         string
+	data          any
+	assumeObjects bool
+	output        string // expected output
+	err           string // expected error, if any
+}
+
+var strictTests = []strictTest{
+	// strict mode
+	{"missing property", "{{hello}}", map[string]any{}, false, "", `"hello" not defined on line 1`},
+	{"missing child", "{{hello.bar}}", map[string]any{"hello": map[string]any{}}, false, "", `"hello.bar" not defined on line 1`},
+	{"missing parent", "\n{{hello.bar.baz}}", map[string]any{"hello": map[string]any{}}, false, "", `"hello.bar.baz" not defined on line 2: "hello.bar" is not defined`},
+	{"explicit nil", "{{hello.bar}}", map[string]any{"hello": map[string]any{"bar": nil}}, false, "", ""},
+	{"missing block", "{{#hello}}foo{{/hello}}", map[string]any{}, false, "", `"hello" not defined on line 1`},
+	{"missing data", "{{@hello}}", map[string]any{}, false, "", `"@hello" not defined on line 1`},
+	{"missing struct field", "{{user.fristName}}", map[string]any{"user": Author{"Alan", "Johnson"}}, false, "", `"user.fristName" not defined on line 1`},
+	{"struct field", "{{user.firstName}}", map[string]any{"user": Author{"Alan", "Johnson"}}, false, "Alan", ""},
+	{"parent context", "{{#hello}}{{foo}}{{/hello}}", map[string]any{"hello": map[string]any{"baz": 1}, "foo": "bar"}, false, "bar", ""},
+	{"missing helper param", "{{#unless foo}}success{{/unless}}", map[string]any{}, false, "success", ""},
+	{"missing helper hash", "{{#if true includeZero=@foo}}success{{/if}}", map[string]any{}, false, "success", ""},
+	{"missing helper param parent", "{{#unless foo.bar}}success{{/unless}}", map[string]any{}, false, "", `"foo.bar" not defined on line 1: "foo" is not defined`},
+
+	// assume objects mode
+	{"assume objects: missing property", "{{hello}}", map[string]any{}, true, "", ""},
+	{"assume objects: missing child", "{{hello.bar}}", map[string]any{"hello": map[string]any{}}, true, "", ""},
+	{"assume objects: missing object", "{{hello.bar}}", map[string]any{}, true, "", `"hello.bar" not defined on line 1: "hello" is not defined`},
+	{"assume objects: missing data", "{{@hello.bar}}", map[string]any{}, true, "", `"@hello.bar" not defined on line 1: "hello" is not defined`},
+}
+
+func TestEvalStrict(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range strictTests {
+		tpl := MustParse(test.input)
+		if test.assumeObjects {
+			tpl.SetAssumeObjects(true)
+		} else {
+			tpl.SetStrict(true)
+		}
+
+		output, err := tpl.Exec(test.data)
+		if test.err != "" {
+			if err == nil {
+				t.Errorf("Test '%s' failed - Error expected, got: %q", test.name, output)
+			} else if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test '%s' failed - Incorrect error returned\nexpected\n\t%q\ngot\n\t%q", test.name, test.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+
+		// default mode never fails
+		if _, err := MustParse(test.input).Exec(test.data); err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error in default mode: %s", test.name, err)
+		}
+	}
+}
+
+var compatTests = []struct {
+	name   string
+	input  string
+	data   any
+	output string
+	compat string
+}{
+	{
+		"first part resolution",
+		`{{#a}}{{b}}{{/a}}`,
+		map[string]any{"a": map[string]string{"x": "y"}, "b": "root"},
+		"root",
+		"root",
+	},
+	{
+		"dotted name not resolved in nearest context",
+		`{{#a}}{{b.c}}{{/a}}`,
+		map[string]any{"a": map[string]any{"b": map[string]string{"x": "y"}}, "b": map[string]string{"c": "root"}},
+		"",
+		"root",
+	},
+	{
+		"dotted name resolved in nearest context",
+		`{{#a}}{{b.c}}{{/a}}`,
+		map[string]any{"a": map[string]any{"b": map[string]string{"c": "a"}}, "b": map[string]string{"c": "root"}},
+		"a",
+		"a",
+	},
+	{
+		"nested contexts",
+		`{{#a}}{{#b}}{{c.d.e}}{{/b}}{{/a}}`,
+		map[string]any{
+			"a": map[string]any{
+				"b": map[string]any{"c": map[string]string{"d": "b"}},
+				"c": map[string]any{"d": map[string]string{"e": "a"}},
+			},
+			"c": map[string]any{"d": map[string]string{"e": "root"}},
+		},
+		"",
+		"a",
+	},
+	{
+		"helper params",
+		`{{#a}}{{#if b.c}}yes{{/if}}{{/a}}`,
+		map[string]any{"a": map[string]any{"b": map[string]string{"x": "y"}}, "b": map[string]bool{"c": true}},
+		"",
+		"yes",
+	},
+}
+
+func TestEvalCompat(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range compatTests {
+		tpl := MustParse(test.input)
+
+		if output := tpl.MustExec(test.data); output != test.output {
+			t.Errorf("Test '%s' failed in default mode\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+
+		tpl.SetCompat(true)
+
+		if output := tpl.MustExec(test.data); output != test.compat {
+			t.Errorf("Test '%s' failed in compat mode\nexpected\n\t%q\ngot\n\t%q", test.name, test.compat, output)
+		}
+	}
+
+	// missing paths are still reported in strict mode
+	tpl := MustParse(`{{#a}}{{b.c}}{{/a}}`)
+	tpl.SetCompat(true)
+	tpl.SetStrict(true)
+
+	if _, err := tpl.Exec(map[string]any{"a": map[string]any{"b": map[string]string{"x": "y"}}}); (err == nil) || !strings.Contains(err.Error(), `"b.c" not defined`) {
+		t.Errorf("Unexpected error in strict compat mode: %v", err)
+	}
+}
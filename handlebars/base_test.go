@@ -26,6 +26,11 @@ type Test struct {
 }
 
 func launchTests(t *testing.T, tests []Test) {
+	launchTestsWith(t, tests, nil)
+}
+
+// launchTestsWith runs given tests, after calling setup on each parsed template
+func launchTestsWith(t *testing.T, tests []Test, setup func(tpl *raymond.Template)) {
 	t.Parallel()
 
 	for _, test := range tests {
@@ -45,6 +50,10 @@ func launchTests(t *testing.T, tests []Test) {
 		if err != nil {
 			t.Errorf("Test '%s' failed - Failed to parse template\ninput:\n\t'%s'\nerror:\n\t%s", test.name, test.input, err)
 		} else {
+			if setup != nil {
+				setup(tpl)
+			}
+
 			if len(test.helpers) > 0 {
 				// register helpers
 				tpl.RegisterHelpers(test.helpers)
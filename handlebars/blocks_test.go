@@ -1,6 +1,10 @@
 package handlebars
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/yoinkai/raymond/v2"
+)
 
 // Those tests come from:
 //
@@ -173,26 +177,6 @@ var blocksTests = []Test{
 		nil, nil, nil,
 		"1\n3\n5\nOK.",
 	},
-	// // @todo compat mode
-	// {
-	// 	"block with deep recursive lookup lookup",
-	// 	"{{#outer}}Goodbye {{#inner}}cruel {{omg}}{{/inner}}{{/outer}}",
-	// 	map[string]any{"omg": "OMG!", "outer": []map[string]any{{"inner": []map[string]string{{"text": "goodbye"}}}}},
-	// 	nil,
-	// 	nil,
-	//  nil,
-	// 	"Goodbye cruel OMG!",
-	// },
-	// // @todo compat mode
-	// {
-	// 	"block with deep recursive pathed lookup",
-	// 	"{{#outer}}Goodbye {{#inner}}cruel {{omg.yes}}{{/inner}}{{/outer}}",
-	// 	map[string]any{"omg": map[string]string{"yes": "OMG!"}, "outer": []map[string]any{{"inner": []map[string]string{{"yes": "no", "text": "goodbye"}}}}},
-	// 	nil,
-	// 	nil,
-	//  nil,
-	// 	"Goodbye cruel OMG!",
-	// },
 	{
 		"block with missed recursive lookup",
 		"{{#outer}}Goodbye {{#inner}}cruel {{omg.yes}}{{/inner}}{{/outer}}",
@@ -200,8 +184,46 @@ var blocksTests = []Test{
 		nil, nil, nil,
 		"Goodbye cruel ",
 	},
+	{
+		"block with missed pathed lookup in parent context",
+		"{{#outer}}Goodbye {{#inner}}cruel {{omg.yes}}{{/inner}}{{/outer}}",
+		map[string]any{"omg": map[string]string{"yes": "OMG!"}, "outer": []map[string]any{{"omg": map[string]string{"no": "OMG!"}, "inner": []map[string]string{{"text": "goodbye"}}}}},
+		nil, nil, nil,
+		"Goodbye cruel ",
+	},
+}
+
+// Those tests come from the "compat mode" section of spec/blocks.js, they are run with compat mode enabled
+var blocksCompatTests = []Test{
+	{
+		"block with deep recursive lookup lookup",
+		"{{#outer}}Goodbye {{#inner}}cruel {{omg}}{{/inner}}{{/outer}}",
+		map[string]any{"omg": "OMG!", "outer": []map[string]any{{"inner": []map[string]string{{"text": "goodbye"}}}}},
+		nil, nil, nil,
+		"Goodbye cruel OMG!",
+	},
+	{
+		"block with deep recursive pathed lookup",
+		"{{#outer}}Goodbye {{#inner}}cruel {{omg.yes}}{{/inner}}{{/outer}}",
+		map[string]any{"omg": map[string]string{"yes": "OMG!"}, "outer": []map[string]any{{"inner": []map[string]string{{"yes": "no", "text": "goodbye"}}}}},
+		nil, nil, nil,
+		"Goodbye cruel OMG!",
+	},
+	{
+		"block with deep recursive pathed lookup in parent context",
+		"{{#outer}}Goodbye {{#inner}}cruel {{omg.yes}}{{/inner}}{{/outer}}",
+		map[string]any{"omg": map[string]string{"yes": "OMG!"}, "outer": []map[string]any{{"omg": map[string]string{"no": "OMG!"}, "inner": []map[string]string{{"text": "goodbye"}}}}},
+		nil, nil, nil,
+		"Goodbye cruel OMG!",
+	},
 }
 
 func TestBlocks(t *testing.T) {
 	launchTests(t, blocksTests)
 }
+
+func TestBlocksCompat(t *testing.T) {
+	launchTestsWith(t, blocksCompatTests, func(tpl *raymond.Template) {
+		tpl.SetCompat(true)
+	})
+}
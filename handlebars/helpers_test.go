@@ -92,6 +92,18 @@ func variadicHelper(a string, b ...bool) string {
 	return "FALSE " + a
 }
 
+var nameFieldHelpers = map[string]any{
+	"blockHelperMissing": func(options *raymond.Options) string {
+		return "missing: " + options.Name()
+	},
+	"helperMissing": func(options *raymond.Options) string {
+		return "helper missing: " + options.Name()
+	},
+	"helper": func(options *raymond.Options) string {
+		return "ran: " + options.Name()
+	},
+}
+
 // Those tests come from:
 //
 // https://github.com/wycats/handlebars.js/blob/master/spec/helper.js
@@ -558,11 +570,37 @@ var helpersTests = []Test{
 		"NOT PRINTING",
 	},
 
+	// @note raymond does not throw when helperMissing is not registered
 	// @todo "helperMissing - if a context is not found, helperMissing is used" throw error
 
-	// @todo "helperMissing - if a context is not found, custom helperMissing is used"
-
-	// @todo "helperMissing - if a value is not found, custom helperMissing is used"
+	{
+		"helperMissing - if a context is not found, custom helperMissing is used",
+		`{{hello}} {{link_to world}}`,
+		map[string]string{"hello": "Hello", "world": "world"},
+		nil,
+		map[string]any{"helperMissing": func(mesg string, options *raymond.Options) any {
+			if options.Name() == "link_to" {
+				return raymond.SafeString("<a>" + mesg + "</a>")
+			}
+			return nil
+		}},
+		nil,
+		"Hello <a>world</a>",
+	},
+	{
+		"helperMissing - if a value is not found, custom helperMissing is used",
+		`{{hello}} {{link_to}}`,
+		map[string]string{"hello": "Hello", "world": "world"},
+		nil,
+		map[string]any{"helperMissing": func(options *raymond.Options) any {
+			if options.Name() == "link_to" {
+				return raymond.SafeString("<a>winning</a>")
+			}
+			return nil
+		}},
+		nil,
+		"Hello <a>winning</a>",
+	},
 
 	{
 		"block helpers can take an optional hash with booleans (1)",
@@ -583,11 +621,54 @@ var helpersTests = []Test{
 		"NOT PRINTING",
 	},
 
-	// @todo "knownHelpers/knownHelpersOnly" tests
+	// @note "knownHelpers/knownHelpersOnly" tests are in known_helpers_test.go, as they need an environment
 
 	// @todo "blockHelperMissing" tests
 
-	// @todo "name field" tests
+	{
+		"name field - should include in ambiguous mustache calls",
+		`{{helper}}`,
+		nil, nil,
+		nameFieldHelpers,
+		nil,
+		"ran: helper",
+	},
+	// @todo "name field - should include in helper mustache calls" helper with variable arity
+	{
+		"name field - should include in ambiguous block calls",
+		`{{#helper}}{{/helper}}`,
+		nil, nil,
+		nameFieldHelpers,
+		nil,
+		"ran: helper",
+	},
+	{
+		"name field - should include in simple block calls",
+		`{{#./helper}}{{/./helper}}`,
+		nil, nil,
+		nameFieldHelpers,
+		nil,
+		"missing: ./helper",
+	},
+	// @todo "name field - should include in helper block calls" helper with variable arity
+	{
+		"name field - should include full id",
+		`{{#foo.helper}}{{/foo.helper}}`,
+		map[string]any{"foo": map[string]string{}},
+		nil,
+		nameFieldHelpers,
+		nil,
+		"missing: foo.helper",
+	},
+	{
+		"name field - should include full id if a hash is passed",
+		`{{#foo.helper bar=baz}}{{/foo.helper}}`,
+		map[string]any{"foo": map[string]string{}},
+		nil,
+		nameFieldHelpers,
+		nil,
+		"helper missing: foo.helper",
+	},
 
 	{
 		"name conflicts - helpers take precedence over same-named context properties",
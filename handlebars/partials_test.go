@@ -1,6 +1,11 @@
 package handlebars
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/yoinkai/raymond/v2"
+)
 
 // Those tests come from:
 //
@@ -45,7 +50,7 @@ var partialsTests = []Test{
 		"Dudes:  Empty",
 	},
 
-	// @todo "partials with duplicate parameters"
+	// "partials with duplicate parameters" is tested in TestPartialsDuplicateParameters
 
 	{
 		"partials with parameters",
@@ -55,6 +60,32 @@ var partialsTests = []Test{
 		map[string]string{"dude": "{{others.foo}}{{name}} ({{url}}) "},
 		"Dudes: barYehuda (http://yehuda) barAlan (http://alan) ",
 	},
+
+	// raymond specific tests
+	{
+		"partials with context and parameters",
+		"Dudes: {{> dude author suffix=\"!\"}}",
+		map[string]any{"author": map[string]string{"name": "Yehuda", "url": "http://yehuda"}},
+		nil, nil,
+		map[string]string{"dude": "{{name}} ({{url}}){{suffix}}"},
+		"Dudes: Yehuda (http://yehuda)!",
+	},
+	{
+		"partials parameters override context",
+		"Dudes: {{> dude author name=\"Alan\"}} {{author.name}}",
+		map[string]any{"author": map[string]string{"name": "Yehuda", "url": "http://yehuda"}},
+		nil, nil,
+		map[string]string{"dude": "{{name}} ({{url}})"},
+		"Dudes: Alan (http://yehuda) Yehuda",
+	},
+	{
+		"partials parameters extend current context",
+		"Dudes: {{#each dudes}}{{> dude suffix=\"!\"}}{{/each}}",
+		map[string]any{"dudes": []map[string]string{{"name": "Yehuda"}, {"name": "Alan"}}},
+		nil, nil,
+		map[string]string{"dude": "{{name}}{{suffix}} "},
+		"Dudes: Yehuda! Alan! ",
+	},
 	{
 		"partial in a partial",
 		"Dudes: {{#dudes}}{{>dude}}{{/dudes}}",
@@ -163,19 +194,315 @@ var partialsTests = []Test{
 		"Dudes:\n  Yehuda\n   http://yehuda!\n  Alan\n   http://alan!\n",
 	},
 
-	// // @todo preventIndent option
-	// {
-	// 	"standalone partials (3) - prevent nested indented partials",
-	// 	"Dudes:\n{{#dudes}}\n  {{>dude}}\n{{/dudes}}",
-	// 	map[string]any{"dudes": []map[string]string{{"name": "Yehuda", "url": "http://yehuda"}, {"name": "Alan", "url": "http://alan"}}},
-	// 	nil, nil,
-	// 	map[string]string{"dude": "{{name}}\n {{> url}}", "url": "{{url}}!\n"},
-	// 	"Dudes:\n  Yehuda\n http://yehuda!\n  Alan\n http://alan!\n",
-	// },
+	// @note "standalone partials (3) - prevent nested indented partials" is tested by TestPartialsPreventIndent
 
 	// @todo "compat mode"
+
+	// raymond specific tests
+	{
+		"should not strip whitespace between partials on the same line",
+		"{{> dude}} {{> dude}}",
+		nil, nil, nil,
+		map[string]string{"dude": "success"},
+		"success success",
+	},
 }
 
 func TestPartials(t *testing.T) {
 	launchTests(t, partialsTests)
 }
+
+var inlinePartialsTests = []Test{
+	{
+		"should define inline partials for template",
+		`{{#*inline "myPartial"}}success{{/inline}}{{> myPartial}}`,
+		nil, nil, nil, nil,
+		"success",
+	},
+	{
+		"should overwrite multiple partials in the same template",
+		`{{#*inline "myPartial"}}fail{{/inline}}{{#*inline "myPartial"}}success{{/inline}}{{> myPartial}}`,
+		nil, nil, nil, nil,
+		"success",
+	},
+	{
+		"should define inline partials for block",
+		`{{#with .}}{{#*inline "myPartial"}}success{{/inline}}{{> myPartial}}{{/with}}`,
+		map[string]string{"foo": "bar"}, nil, nil, nil,
+		"success",
+	},
+	{
+		"should override global partials",
+		`{{#*inline "myPartial"}}success{{/inline}}{{> myPartial}}`,
+		nil, nil, nil,
+		map[string]string{"myPartial": "fail"},
+		"success",
+	},
+	{
+		"should override template partials",
+		`{{#*inline "myPartial"}}fail{{/inline}}{{#with .}}{{#*inline "myPartial"}}success{{/inline}}{{> myPartial}}{{/with}}`,
+		map[string]string{"foo": "bar"}, nil, nil, nil,
+		"success",
+	},
+	{
+		"should override partials down the entire stack",
+		`{{#with .}}{{#*inline "myPartial"}}success{{/inline}}{{#with .}}{{#with .}}{{> myPartial}}{{/with}}{{/with}}{{/with}}`,
+		map[string]string{"foo": "bar"}, nil, nil, nil,
+		"success",
+	},
+	{
+		"should define inline partials for partial call",
+		`{{#*inline "myPartial"}}success{{/inline}}{{> dude}}`,
+		nil, nil, nil,
+		map[string]string{"dude": "{{> myPartial }}"},
+		"success",
+	},
+
+	// raymond specific tests
+	{
+		"should define inline partials before use",
+		`{{> myPartial}}{{#*inline "myPartial"}}{{name}}{{/inline}}`,
+		map[string]string{"name": "success"}, nil, nil, nil,
+		"success",
+	},
+	{
+		"should strip standalone inline partials",
+		"{{#*inline \"myPartial\"}}\n  <b>{{name}}</b>\n{{/inline}}\n{{> myPartial}}",
+		map[string]string{"name": "success"}, nil, nil, nil,
+		"  <b>success</b>\n",
+	},
+}
+
+func TestPartialsDuplicateParameters(t *testing.T) {
+	t.Parallel()
+
+	tpl := raymond.MustParse("Dudes: {{> dude dudes foo bar=baz}}")
+	tpl.RegisterPartial("dude", "{{name}}")
+
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Unsupported number of partial arguments: 2") {
+		t.Errorf("Unexpected error for a partial with several contexts: %v", err)
+	}
+
+	if _, err := raymond.Parse("Dudes: {{> dude foo=bar foo=baz}}"); (err == nil) || !strings.Contains(err.Error(), "Duplicate partial parameter: foo") {
+		t.Errorf("Unexpected error for a partial with duplicate parameters: %v", err)
+	}
+}
+
+func TestPartialsPreventIndent(t *testing.T) {
+	t.Parallel()
+
+	tpl := raymond.MustParse("Dudes:\n{{#dudes}}\n  {{>dude}}\n{{/dudes}}")
+	tpl.SetPreventIndent(true)
+	tpl.RegisterPartials(map[string]string{"dude": "{{name}}\n {{> url}}", "url": "{{url}}!\n"})
+
+	ctx := map[string]any{"dudes": []map[string]string{{"name": "Yehuda", "url": "http://yehuda"}, {"name": "Alan", "url": "http://alan"}}}
+
+	if output, expected := tpl.MustExec(ctx), "Dudes:\n  Yehuda\n http://yehuda!\n  Alan\n http://alan!\n"; output != expected {
+		t.Errorf("Unexpected output with prevent indent\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+}
+
+func TestInlinePartials(t *testing.T) {
+	launchTests(t, inlinePartialsTests)
+}
+
+func TestInlinePartialsScope(t *testing.T) {
+	t.Parallel()
+
+	tpl := raymond.MustParse(`{{#with .}}{{#*inline "myPartial"}}success{{/inline}}{{/with}}{{> myPartial}}`)
+	if _, err := tpl.Exec(map[string]string{"foo": "bar"}); (err == nil) || !strings.Contains(err.Error(), "Partial not found: myPartial") {
+		t.Errorf("Unexpected error for an inline partial out of its block: %v", err)
+	}
+
+	tpl = raymond.MustParse(`{{#*inline myPartial}}fail{{/inline}}`)
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Inline partial name must be a string") {
+		t.Errorf("Unexpected error for an inline partial without string name: %v", err)
+	}
+
+	tpl = raymond.MustParse(`{{#*foo}}fail{{/foo}}`)
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Decorator not found: foo") {
+		t.Errorf("Unexpected error for an unknown decorator: %v", err)
+	}
+}
+
+//
+// Handlebars.js partial blocks tests
+//
+
+var partialBlocksTests = []Test{
+	{
+		"should render partial block as default",
+		`{{#> dude}}success{{/dude}}`,
+		nil, nil, nil, nil,
+		"success",
+	},
+	{
+		"should execute default block with proper context",
+		`{{#> dude context}}{{value}}{{/dude}}`,
+		map[string]any{"context": map[string]string{"value": "success"}}, nil, nil, nil,
+		"success",
+	},
+	{
+		"should propagate block parameters to default block",
+		`{{#with context as |me|}}{{#> dude}}{{me.value}}{{/dude}}{{/with}}`,
+		map[string]any{"context": map[string]string{"value": "success"}}, nil, nil, nil,
+		"success",
+	},
+	{
+		"should not use partial block if partial exists",
+		`{{#> dude}}fail{{/dude}}`,
+		nil, nil, nil,
+		map[string]string{"dude": "success"},
+		"success",
+	},
+	{
+		"should render block from partial",
+		`{{#> dude}}success{{/dude}}`,
+		nil, nil, nil,
+		map[string]string{"dude": "{{> @partial-block }}"},
+		"success",
+	},
+	{
+		"should be able to render the partial-block twice",
+		`{{#> dude}}success{{/dude}}`,
+		nil, nil, nil,
+		map[string]string{"dude": "{{> @partial-block }} {{> @partial-block }}"},
+		"success success",
+	},
+	{
+		"should render block from partial with context",
+		`{{#> dude}}{{value}}{{/dude}}`,
+		map[string]any{"context": map[string]string{"value": "success"}}, nil, nil,
+		map[string]string{"dude": "{{#with context}}{{> @partial-block }}{{/with}}"},
+		"success",
+	},
+	{
+		"should be able to access the @data frame from a partial-block",
+		`{{#> dude}}in-block: {{@root/value}}{{/dude}}`,
+		map[string]string{"value": "success"}, nil, nil,
+		map[string]string{"dude": "<code>before-block: {{@root/value}} {{>   @partial-block }}</code>"},
+		"<code>before-block: success in-block: success</code>",
+	},
+	{
+		"should allow the #each-helper to be used along with partial-blocks",
+		`<template>{{#> list value}}value = {{.}}{{/list}}</template>`,
+		map[string]any{"value": []string{"a", "b", "c"}}, nil, nil,
+		map[string]string{"list": "<list>{{#each .}}<item>{{> @partial-block}}</item>{{/each}}</list>"},
+		"<template><list><item>value = a</item><item>value = b</item><item>value = c</item></list></template>",
+	},
+	{
+		"should render block from partial with context (twice)",
+		`{{#> dude}}{{value}}{{/dude}}`,
+		map[string]any{"context": map[string]string{"value": "success"}}, nil, nil,
+		map[string]string{"dude": "{{#with context}}{{> @partial-block }} {{> @partial-block }}{{/with}}"},
+		"success success",
+	},
+	{
+		"should render block from partial with context",
+		`{{#> dude}}{{../context/value}}{{/dude}}`,
+		map[string]any{"context": map[string]string{"value": "success"}}, nil, nil,
+		map[string]string{"dude": "{{#with context}}{{> @partial-block }}{{/with}}"},
+		"success",
+	},
+	{
+		"should render block from partial with block params",
+		`{{#with context as |me|}}{{#> dude}}{{me.value}}{{/dude}}{{/with}}`,
+		map[string]any{"context": map[string]string{"value": "success"}}, nil, nil,
+		map[string]string{"dude": "{{> @partial-block }}"},
+		"success",
+	},
+	{
+		"should render nested partial blocks",
+		`<template>{{#> outer}}{{value}}{{/outer}}</template>`,
+		map[string]string{"value": "success"}, nil, nil,
+		map[string]string{
+			"outer":  "<outer>{{#> nested}}<outer-block>{{> @partial-block}}</outer-block>{{/nested}}</outer>",
+			"nested": "<nested>{{> @partial-block}}</nested>",
+		},
+		"<template><outer><nested><outer-block>success</outer-block></nested></outer></template>",
+	},
+	{
+		"should render nested partial blocks at different nesting levels",
+		`<template>{{#> outer}}{{value}}{{/outer}}</template>`,
+		map[string]string{"value": "success"}, nil, nil,
+		map[string]string{
+			"outer":  "<outer>{{#> nested}}<outer-block>{{> @partial-block}}</outer-block>{{/nested}}{{> @partial-block}}</outer>",
+			"nested": "<nested>{{> @partial-block}}</nested>",
+		},
+		"<template><outer><nested><outer-block>success</outer-block></nested>success</outer></template>",
+	},
+	{
+		"should render nested partial blocks at different nesting levels (twice)",
+		`<template>{{#> outer}}{{value}}{{/outer}}</template>`,
+		map[string]string{"value": "success"}, nil, nil,
+		map[string]string{
+			"outer":  "<outer>{{#> nested}}<outer-block>{{> @partial-block}} {{> @partial-block}}</outer-block>{{/nested}}{{> @partial-block}}+{{> @partial-block}}</outer>",
+			"nested": "<nested>{{> @partial-block}}</nested>",
+		},
+		"<template><outer><nested><outer-block>success success</outer-block></nested>success+success</outer></template>",
+	},
+	{
+		"should render nested partial blocks (twice at each level)",
+		`<template>{{#> outer}}{{value}}{{/outer}}</template>`,
+		map[string]string{"value": "success"}, nil, nil,
+		map[string]string{
+			"outer":  "<outer>{{#> nested}}<outer-block>{{> @partial-block}} {{> @partial-block}}</outer-block>{{/nested}}</outer>",
+			"nested": "<nested>{{> @partial-block}}{{> @partial-block}}</nested>",
+		},
+		"<template><outer>" +
+			"<nested><outer-block>success success</outer-block><outer-block>success success</outer-block></nested>" +
+			"</outer></template>",
+	},
+	{
+		"should render inline partials in partial block call",
+		`{{#> dude}}{{#*inline "myPartial"}}success{{/inline}}{{/dude}}`,
+		nil, nil, nil,
+		map[string]string{"dude": "{{> myPartial }}"},
+		"success",
+	},
+	{
+		"should render nested inline partials",
+		`{{#*inline "outer"}}{{#>inner}}<outer-block>{{>@partial-block}}</outer-block>{{/inner}}{{/inline}}` +
+			`{{#*inline "inner"}}<inner>{{>@partial-block}}</inner>{{/inline}}` +
+			`{{#>outer}}{{value}}{{/outer}}`,
+		map[string]string{"value": "success"}, nil, nil, nil,
+		"<inner><outer-block>success</outer-block></inner>",
+	},
+	{
+		"should render nested inline partials with partial-blocks on different nesting levels",
+		`{{#*inline "outer"}}{{#>inner}}<outer-block>{{>@partial-block}}</outer-block>{{/inner}}{{>@partial-block}}{{/inline}}` +
+			`{{#*inline "inner"}}<inner>{{>@partial-block}}</inner>{{/inline}}` +
+			`{{#>outer}}{{value}}{{/outer}}`,
+		map[string]string{"value": "success"}, nil, nil, nil,
+		"<inner><outer-block>success</outer-block></inner>success",
+	},
+
+	// raymond specific tests
+	{
+		"should strip standalone partial blocks",
+		"{{#> dude}}\n  fail\n{{/dude}}\n",
+		nil, nil, nil,
+		map[string]string{"dude": "<b>{{> @partial-block}}</b>"},
+		"<b>  fail\n</b>",
+	},
+	{
+		"should indent standalone partial-block",
+		"{{#> dude}}\nsuccess\n{{/dude}}\n",
+		nil, nil, nil,
+		map[string]string{"dude": "<b>\n  {{> @partial-block}}\n</b>"},
+		"<b>\n  success\n</b>",
+	},
+}
+
+func TestPartialBlocks(t *testing.T) {
+	launchTests(t, partialBlocksTests)
+}
+
+func TestPartialBlockNotFound(t *testing.T) {
+	t.Parallel()
+
+	tpl := raymond.MustParse(`{{> @partial-block}}`)
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Partial not found: @partial-block") {
+		t.Errorf("Unexpected error for a partial-block out of a partial block: %v", err)
+	}
+}
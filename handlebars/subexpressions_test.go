@@ -182,9 +182,7 @@ var subexpressionsTests = []Test{
 		`<input aria-label="Name" placeholder="Example User" />`,
 	},
 
-	// @todo "in string params mode"
-
-	// @todo "as hashes in string params mode"
+	// @note "in string params mode" and "as hashes in string params mode" are tested by TestSubexpressionsStringParams
 
 	{
 		"subexpression functions on the context",
@@ -206,3 +204,51 @@ var subexpressionsTests = []Test{
 func TestSubexpressions(t *testing.T) {
 	launchTests(t, subexpressionsTests)
 }
+
+func TestSubexpressionsStringParams(t *testing.T) {
+	t.Parallel()
+
+	// in string params mode
+	tpl := raymond.MustParse(`{{snog (blorg foo x=y) yeah a=b}}`)
+	tpl.SetStringParams(true)
+	tpl.RegisterHelpers(map[string]any{
+		"snog": func(a, b string, options *raymond.Options) string {
+			if (options.ParamType(0) != raymond.ParamTypeSubExpression) || (options.ParamType(1) != raymond.ParamTypeID) {
+				t.Errorf("String params for outer helper processed incorrectly: %s %s", options.ParamType(0), options.ParamType(1))
+			}
+
+			return a + b
+		},
+		"blorg": func(a string, options *raymond.Options) string {
+			if options.ParamType(0) != raymond.ParamTypeID {
+				t.Errorf("String params for inner helper processed incorrectly: %s", options.ParamType(0))
+			}
+
+			return a
+		},
+	})
+
+	if output := tpl.MustExec(map[string]any{"foo": map[string]string{}, "yeah": map[string]string{}}); output != "fooyeah" {
+		t.Errorf("Unexpected output in string params mode: %q", output)
+	}
+
+	// as hashes in string params mode
+	tpl = raymond.MustParse(`{{blog fun=(bork)}}`)
+	tpl.SetStringParams(true)
+	tpl.RegisterHelpers(map[string]any{
+		"blog": func(options *raymond.Options) string {
+			if options.HashType("fun") != raymond.ParamTypeSubExpression {
+				t.Errorf("Unexpected hash type: %s", options.HashType("fun"))
+			}
+
+			return "val is " + options.HashStr("fun")
+		},
+		"bork": func() string {
+			return "BORK"
+		},
+	})
+
+	if output := tpl.MustExec(nil); output != "val is BORK" {
+		t.Errorf("Unexpected output with hashes in string params mode: %q", output)
+	}
+}
@@ -252,6 +252,21 @@ var whitespaceControlTests = []Test{
 		nil, nil, nil,
 		"barbar bar ",
 	},
+	// @note Test added
+	{
+		"should not strip whitespace followed by a statement after a root standalone",
+		"{{! comment }}  {{foo}}",
+		map[string]string{"foo": "bar"},
+		nil, nil, nil,
+		"  bar",
+	},
+	// @note Test added
+	{
+		"should strip whitespace at end of template after a root standalone",
+		"{{! comment }}  ",
+		nil, nil, nil, nil,
+		"",
+	},
 }
 
 func TestWhitespaceControl(t *testing.T) {
@@ -1,11 +1,31 @@
 package raymond
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"reflect"
 	"regexp"
 	"strconv"
-	"sync"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yoinkai/raymond/v2/ast"
+)
+
+// Names of the helpers called when a helper can't be resolved.
+const (
+	helperMissingName      = "helperMissing"
+	blockHelperMissingName = "blockHelperMissing"
+)
+
+// Types of helper params and hash values, returned by Options.ParamType() and Options.HashType().
+const (
+	ParamTypeID            = "ID"
+	ParamTypeString        = "STRING"
+	ParamTypeNumber        = "NUMBER"
+	ParamTypeBoolean       = "BOOLEAN"
+	ParamTypeSubExpression = "SUBEXPRESSION"
 )
 
 // Options represents the options argument provided to helpers and context functions.
@@ -13,78 +33,37 @@ type Options struct {
 	// evaluation visitor
 	eval *evalVisitor
 
+	// name of called helper
+	name string
+
+	// expression calling helper, nil if helper is not called by an expression
+	expr *ast.Expression
+
 	// params
 	params []any
 	hash   map[string]any
 }
 
-var (
-	// helpers stores all globally registered helpers
-	helpers      = make(map[string]reflect.Value)
-	paramHelpers = make(map[string]paramHelperFunc)
-
-	// protects global helpers
-	helpersMutex sync.RWMutex
-
-	// protects global param helpers
-	paramHelpersMutex sync.RWMutex
-)
-
-func init() {
-	// Register builtin helpers.
-	RegisterHelper("if", ifHelper)
-	RegisterHelper("unless", unlessHelper)
-	RegisterHelper("with", withHelper)
-	RegisterHelper("each", eachHelper)
-	RegisterHelper("log", logHelper)
-	RegisterHelper("lookup", lookupHelper)
-	RegisterHelper("equal", equalHelper)
-	RegisterHelper("ifGt", ifGtHelper)
-	RegisterHelper("ifLt", ifLtHelper)
-	RegisterHelper("ifEq", ifEqHelper)
-	RegisterHelper("ifMatchesRegexStr", ifMatchesRegexStr)
-	RegisterHelper("pluralize", pluralizeHelper)
-
-	// Register builtin param helpers.
-	RegisterParamHelper("length", lengthParamHelper)
-}
-
-// RegisterHelper registers a global helper. That helper will be available to all templates.
+// RegisterHelper registers a global helper. That helper will be available to all templates of the default
+// environment.
 func RegisterHelper(name string, helper any) {
-	helpersMutex.Lock()
-	defer helpersMutex.Unlock()
-
-	if helpers[name] != zero {
-		panic(fmt.Errorf("helper already registered: %s", name))
-	}
-
-	val := reflect.ValueOf(helper)
-	ensureValidHelper(name, val)
-
-	helpers[name] = val
+	defaultEnv.RegisterHelper(name, helper)
 }
 
-// RegisterHelpers registers several global helpers. Those helpers will be available to all templates.
+// RegisterHelpers registers several global helpers. Those helpers will be available to all templates of the default
+// environment.
 func RegisterHelpers(helpers map[string]any) {
-	for name, helper := range helpers {
-		RegisterHelper(name, helper)
-	}
+	defaultEnv.RegisterHelpers(helpers)
 }
 
 // RemoveHelper unregisters a global helper
 func RemoveHelper(name string) {
-	helpersMutex.Lock()
-	defer helpersMutex.Unlock()
-
-	delete(helpers, name)
+	defaultEnv.RemoveHelper(name)
 }
 
 // RemoveAllHelpers unregisters all global helpers
 func RemoveAllHelpers() {
-	helpersMutex.Lock()
-	defer helpersMutex.Unlock()
-
-	helpers = make(map[string]reflect.Value)
+	defaultEnv.RemoveAllHelpers()
 }
 
 // ensureValidHelper panics if given helper is not valid
@@ -102,14 +81,6 @@ func ensureValidHelper(name string, funcValue reflect.Value) {
 	// @todo Check if first returned value is a string, SafeString or any ?
 }
 
-// findHelper finds a globally registered helper
-func findHelper(name string) reflect.Value {
-	helpersMutex.RLock()
-	defer helpersMutex.RUnlock()
-
-	return helpers[name]
-}
-
 // newOptions instanciates a new Options
 func newOptions(eval *evalVisitor, params []any, hash map[string]any) *Options {
 	return &Options{
@@ -147,8 +118,31 @@ func (options *Options) ValueStr(name string) string {
 }
 
 // Ctx returns current evaluation context.
+//
+// In a partial called with hash arguments and a struct context, that is a map of the struct exported fields merged with
+// the hash values.
 func (options *Options) Ctx() any {
-	return options.eval.curCtx().Interface()
+	return thisContext(options.eval.curCtx()).Interface()
+}
+
+// Context returns the context.Context the template is evaluated with.
+//
+// Helpers that perform I/O or long computations should respect its cancellation. It is never nil, and defaults to
+// context.Background() when template is not evaluated with Template.ExecContext() or similar.
+func (options *Options) Context() context.Context {
+	return options.eval.execCtx
+}
+
+// Name returns the name of called helper.
+//
+// It is the name of the missing helper when called from helperMissing or blockHelperMissing.
+func (options *Options) Name() string {
+	return options.name
+}
+
+// log returns the logger of the environment template is bound to
+func (options *Options) log() *logrus.Entry {
+	return options.eval.tpl.env.log()
 }
 
 //
@@ -193,6 +187,133 @@ func (options *Options) Params() []any {
 	return options.params
 }
 
+//
+// Ids and types
+//
+
+// ParamID returns the original path of parameter at given position, like `user.name` for `{{helper user.name}}`.
+//
+// It returns an empty string if trackIds mode is disabled, or if that parameter is not a path.
+func (options *Options) ParamID(pos int) string {
+	if !options.trackIDs() || (len(options.expr.Params) <= pos) {
+		return ""
+	}
+
+	return pathID(options.expr.Params[pos])
+}
+
+// HashID returns the original path of hash property, like `user.name` for `{{helper value=user.name}}`.
+//
+// It returns an empty string if trackIds mode is disabled, or if that property is not a path.
+func (options *Options) HashID(name string) string {
+	if !options.trackIDs() || (options.expr.Hash == nil) {
+		return ""
+	}
+
+	for _, pair := range options.expr.Hash.Pairs {
+		if pair.Key == name {
+			return pathID(pair.Val)
+		}
+	}
+
+	return ""
+}
+
+// ParamType returns the type of parameter at given position: ParamTypeID for a path, ParamTypeString,
+// ParamTypeNumber, ParamTypeBoolean or ParamTypeSubExpression.
+//
+// It returns an empty string if stringParams mode is disabled.
+func (options *Options) ParamType(pos int) string {
+	if !options.stringParams() || (len(options.expr.Params) <= pos) {
+		return ""
+	}
+
+	return paramType(options.expr.Params[pos])
+}
+
+// HashType returns the type of hash property, see ParamType().
+//
+// It returns an empty string if stringParams mode is disabled.
+func (options *Options) HashType(name string) string {
+	if !options.stringParams() || (options.expr.Hash == nil) {
+		return ""
+	}
+
+	for _, pair := range options.expr.Hash.Pairs {
+		if pair.Key == name {
+			return paramType(pair.Val)
+		}
+	}
+
+	return ""
+}
+
+// stringParams returns true if helper is called in stringParams mode
+func (options *Options) stringParams() bool {
+	return options.eval.opts.stringParams && (options.expr != nil)
+}
+
+// trackIDs returns true if ids of params and hash are tracked for that helper call
+func (options *Options) trackIDs() bool {
+	return options.eval.opts.trackIDs && (options.expr != nil)
+}
+
+// setContextPath sets @contextPath in given data frame, for the context given as first param, followed by given key if
+// not nil. It does nothing if trackIds mode is disabled.
+func (options *Options) setContextPath(data *DataFrame, key any) {
+	if !options.trackIDs() {
+		return
+	}
+
+	path := appendContextPath(options.DataStr("contextPath"), options.ParamID(0))
+	if key != nil {
+		path = appendContextPath(path, Str(key))
+	}
+
+	data.Set("contextPath", path)
+}
+
+// pathID returns the path of given node, without `this` prefix, or an empty string if it is not a path
+func pathID(node ast.Node) string {
+	path, ok := node.(*ast.PathExpression)
+	if !ok {
+		return ""
+	}
+
+	if path.Data || (path.Depth > 0) {
+		return path.Original
+	}
+
+	return strings.Join(path.Parts, ".")
+}
+
+// paramType returns the type of given helper param or hash value
+func paramType(node ast.Node) string {
+	switch node.(type) {
+	case *ast.PathExpression:
+		return ParamTypeID
+	case *ast.StringLiteral:
+		return ParamTypeString
+	case *ast.NumberLiteral:
+		return ParamTypeNumber
+	case *ast.BooleanLiteral:
+		return ParamTypeBoolean
+	case *ast.SubExpression:
+		return ParamTypeSubExpression
+	}
+
+	return ""
+}
+
+// appendContextPath appends given id to context path
+func appendContextPath(contextPath string, id string) string {
+	if (contextPath == "") || (id == "") {
+		return contextPath + id
+	}
+
+	return contextPath + "." + id
+}
+
 //
 // Private data
 //
@@ -230,13 +351,16 @@ func (options *Options) newIterDataFrame(length int, i int, key any) *DataFrame
 
 // evalBlock evaluates block with given context, private data and iteration key
 func (options *Options) evalBlock(ctx any, data *DataFrame, key any) string {
-	result := ""
+	return options.eval.capture(func() {
+		options.writeBlock(ctx, data, key)
+	})
+}
 
+// writeBlock evaluates block with given context, private data and iteration key, and writes result to template output
+func (options *Options) writeBlock(ctx any, data *DataFrame, key any) {
 	if block := options.eval.curBlock(); (block != nil) && (block.Program != nil) {
-		result = options.eval.evalProgram(block.Program, ctx, data, key)
+		options.eval.writeProgram(block.Program, ctx, data, key)
 	}
-
-	return result
 }
 
 // Fn evaluates block with current evaluation context.
@@ -244,6 +368,26 @@ func (options *Options) Fn() string {
 	return options.evalBlock(nil, nil, nil)
 }
 
+// RawContent returns the unparsed content of a raw block, like ` {{bar}} ` for `{{{{foo}}}} {{bar}} {{{{/foo}}}}`.
+//
+// It returns an empty string if helper is not called as a raw block.
+func (options *Options) RawContent() string {
+	if !options.isBlock() {
+		return ""
+	}
+
+	block := options.eval.curBlock()
+	if !block.Raw || (block.Program == nil) || (len(block.Program.Body) == 0) {
+		return ""
+	}
+
+	if content, ok := block.Program.Body[0].(*ast.ContentStatement); ok {
+		return content.Original
+	}
+
+	return ""
+}
+
 // FnCtxData evaluates block with given context and private data frame.
 func (options *Options) FnCtxData(ctx any, data *DataFrame) string {
 	return options.evalBlock(ctx, data, nil)
@@ -261,12 +405,43 @@ func (options *Options) FnData(data *DataFrame) string {
 
 // Inverse evaluates "else block".
 func (options *Options) Inverse() string {
-	result := ""
+	return options.eval.capture(options.WriteInverse)
+}
+
+//
+// Streaming evaluation
+//
+// Those methods write the evaluated block directly to template output instead of returning it. A block helper
+// that uses them should return an empty string, or what it wants to output after the block.
+//
+
+// Writer returns the writer the template output is currently written to.
+//
+// Content written to that writer is not escaped.
+func (options *Options) Writer() io.Writer {
+	return options.eval.out
+}
+
+// WriteFn evaluates block with current evaluation context and writes result to template output.
+func (options *Options) WriteFn() {
+	options.writeBlock(nil, nil, nil)
+}
+
+// WriteFnCtxData evaluates block with given context and private data frame and writes result to template output.
+func (options *Options) WriteFnCtxData(ctx any, data *DataFrame) {
+	options.writeBlock(ctx, data, nil)
+}
+
+// WriteFnWith evaluates block with given context and writes result to template output.
+func (options *Options) WriteFnWith(ctx any) {
+	options.writeBlock(ctx, nil, nil)
+}
+
+// WriteInverse evaluates "else block" and writes result to template output.
+func (options *Options) WriteInverse() {
 	if block := options.eval.curBlock(); (block != nil) && (block.Inverse != nil) {
-		result, _ = block.Inverse.Accept(options.eval).(string)
+		block.Inverse.Accept(options.eval)
 	}
-
-	return result
 }
 
 // Eval evaluates field for given context.
@@ -291,6 +466,13 @@ func (options *Options) Eval(ctx any, field string) any {
 // Misc
 //
 
+// Escape escapes given string with the escaper of the template being evaluated.
+//
+// It can be used by helpers that return a SafeString and that need to escape some content by themselves.
+func (options *Options) Escape(str string) string {
+	return options.eval.escape(str)
+}
+
 // isIncludableZero returns true if 'includeZero' option is set and first param is the number 0
 func (options *Options) isIncludableZero() bool {
 	b, ok := options.HashProp("includeZero").(bool)
@@ -311,10 +493,12 @@ func (options *Options) isIncludableZero() bool {
 // #if block helper
 func ifHelper(conditional any, options *Options) any {
 	if options.isIncludableZero() || IsTrue(conditional) {
-		return options.Fn()
+		options.WriteFn()
+	} else {
+		options.WriteInverse()
 	}
 
-	return options.Inverse()
+	return ""
 }
 
 func ifGtHelper(a, b any, options *Options) any {
@@ -322,11 +506,11 @@ func ifGtHelper(a, b any, options *Options) any {
 	var err error
 
 	if aFloat, err = floatValue(a); err != nil {
-		log.WithError(err).Errorf("failed to convert value to float '%v'", a)
+		options.log().WithError(err).Errorf("failed to convert value to float '%v'", a)
 		return options.Inverse()
 	}
 	if bFloat, err = floatValue(b); err != nil {
-		log.WithError(err).Errorf("failed to convert value to float '%v'", b)
+		options.log().WithError(err).Errorf("failed to convert value to float '%v'", b)
 		return options.Inverse()
 	}
 
@@ -342,11 +526,11 @@ func ifLtHelper(a, b any, options *Options) any {
 	var err error
 
 	if aFloat, err = floatValue(a); err != nil {
-		log.WithError(err).Errorf("failed to convert value to float '%v'", a)
+		options.log().WithError(err).Errorf("failed to convert value to float '%v'", a)
 		return options.Inverse()
 	}
 	if bFloat, err = floatValue(b); err != nil {
-		log.WithError(err).Errorf("failed to convert value to float '%v'", b)
+		options.log().WithError(err).Errorf("failed to convert value to float '%v'", b)
 		return options.Inverse()
 	}
 
@@ -362,11 +546,11 @@ func ifEqHelper(a, b any, options *Options) any {
 	var err error
 
 	if aFloat, err = floatValue(a); err != nil {
-		log.WithError(err).Errorf("failed to convert value to float '%v'", a)
+		options.log().WithError(err).Errorf("failed to convert value to float '%v'", a)
 		return options.Inverse()
 	}
 	if bFloat, err = floatValue(b); err != nil {
-		log.WithError(err).Errorf("failed to convert value to float '%v'", b)
+		options.log().WithError(err).Errorf("failed to convert value to float '%v'", b)
 		return options.Inverse()
 	}
 
@@ -385,7 +569,7 @@ func ifMatchesRegexStr(a, b any, options *Options) any {
 
 	re, err := regexp.Compile(exp)
 	if err != nil {
-		log.WithError(err).Errorf("failed to compile regex '%v'", a)
+		options.log().WithError(err).Errorf("failed to compile regex '%v'", a)
 		return options.Inverse()
 	}
 
@@ -405,51 +589,66 @@ func pluralizeHelper(count, plural, singular any) any {
 // #unless block helper
 func unlessHelper(conditional any, options *Options) any {
 	if options.isIncludableZero() || IsTrue(conditional) {
-		return options.Inverse()
+		options.WriteInverse()
+	} else {
+		options.WriteFn()
 	}
 
-	return options.Fn()
+	return ""
 }
 
 // #with block helper
 func withHelper(context any, options *Options) any {
-	if IsTrue(context) {
-		return options.FnWith(context)
+	switch {
+	case !IsTrue(context):
+		options.WriteInverse()
+	case options.trackIDs():
+		data := options.NewDataFrame()
+		options.setContextPath(data, nil)
+
+		options.WriteFnCtxData(context, data)
+	default:
+		options.WriteFnWith(context)
 	}
 
-	return options.Inverse()
+	return ""
 }
 
 // #each block helper
 func eachHelper(context any, options *Options) any {
 	if !IsTrue(context) {
-		return options.Inverse()
+		options.WriteInverse()
+		return ""
 	}
 
-	result := ""
-
 	val := reflect.ValueOf(context)
 	switch val.Kind() {
 	case reflect.Array, reflect.Slice:
 		for i := 0; i < val.Len(); i++ {
+			options.eval.checkDone()
+
 			// computes private data
 			data := options.newIterDataFrame(val.Len(), i, nil)
+			options.setContextPath(data, i)
 
 			// evaluates block
-			result += options.evalBlock(val.Index(i).Interface(), data, i)
+			options.writeBlock(val.Index(i).Interface(), data, i)
 		}
 	case reflect.Map:
 		// note: a go hash is not ordered, so result may vary, this behaviour differs from the JS implementation
 		keys := val.MapKeys()
 		for i := 0; i < len(keys); i++ {
+			options.eval.checkDone()
+
 			key := keys[i].Interface()
 			ctx := val.MapIndex(keys[i]).Interface()
 
 			// computes private data
 			data := options.newIterDataFrame(len(keys), i, key)
+			options.setContextPath(data, key)
 
 			// evaluates block
-			result += options.evalBlock(ctx, data, key)
+			options.writeBlock(ctx, data, key)
 		}
 	case reflect.Struct:
 		var exportedFields []int
@@ -462,23 +661,26 @@ func eachHelper(context any, options *Options) any {
 		}
 
 		for i, fieldIndex := range exportedFields {
+			options.eval.checkDone()
+
 			key := val.Type().Field(fieldIndex).Name
 			ctx := val.Field(fieldIndex).Interface()
 
 			// computes private data
 			data := options.newIterDataFrame(len(exportedFields), i, key)
+			options.setContextPath(data, key)
 
 			// evaluates block
-			result += options.evalBlock(ctx, data, key)
+			options.writeBlock(ctx, data, key)
 		}
 	}
 
-	return result
+	return ""
 }
 
 // #log helper
-func logHelper(message string) any {
-	log.Print(message)
+func logHelper(message string, options *Options) any {
+	options.log().Print(message)
 	return ""
 }
 
@@ -525,32 +727,15 @@ func floatValue(value any) (result float64, err error) {
 // operation on it. Such as getting the length of a string, slice, or map.
 type paramHelperFunc func(value reflect.Value) reflect.Value
 
-// RegisterParamHelper registers a global param helper. That helper will be available to all templates.
+// RegisterParamHelper registers a global param helper. That helper will be available to all templates of the default
+// environment.
 func RegisterParamHelper(name string, helper paramHelperFunc) {
-	paramHelpersMutex.Lock()
-	defer paramHelpersMutex.Unlock()
-
-	if _, ok := paramHelpers[name]; ok {
-		panic(fmt.Errorf("Param helper already registered: %s", name))
-	}
-
-	paramHelpers[name] = helper
+	defaultEnv.RegisterParamHelper(name, helper)
 }
 
 // RemoveParamHelper unregisters a global param helper
 func RemoveParamHelper(name string) {
-	paramHelpersMutex.Lock()
-	defer paramHelpersMutex.Unlock()
-
-	delete(paramHelpers, name)
-}
-
-// findParamHelper finds a globally registered param helper
-func findParamHelper(name string) paramHelperFunc {
-	paramHelpersMutex.RLock()
-	defer paramHelpersMutex.RUnlock()
-
-	return paramHelpers[name]
+	defaultEnv.RemoveParamHelper(name)
 }
 
 // lengthParamHelper is a helper func to return the length of the value passed. It
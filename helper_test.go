@@ -1,6 +1,8 @@
 package raymond
 
 import (
+	"context"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
@@ -621,14 +623,220 @@ func TestHelper(t *testing.T) {
 	launchTests(t, helperTests)
 }
 
+func TestHelperWriteFn(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#list items}}{{name}}{{else}}none{{/list}}`)
+	tpl.RegisterHelper("list", func(items []map[string]string, options *Options) SafeString {
+		if len(items) == 0 {
+			options.WriteInverse()
+			return ""
+		}
+
+		w := options.Writer()
+		for _, item := range items {
+			io.WriteString(w, "<li>")
+			options.WriteFnWith(item)
+			io.WriteString(w, "</li>")
+		}
+
+		return "!"
+	})
+
+	result := tpl.MustExec(map[string]any{"items": []map[string]string{{"name": "a&b"}, {"name": "c"}}})
+	if expected := "<li>a&amp;b</li><li>c</li>!"; result != expected {
+		t.Errorf("Unexpected output: %q, expected %q", result, expected)
+	}
+
+	result = tpl.MustExec(map[string]any{"items": []map[string]string{}})
+	if expected := "none"; result != expected {
+		t.Errorf("Unexpected output: %q, expected %q", result, expected)
+	}
+}
+
+type ctxKey struct{}
+
+func TestHelperContext(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{user}}`)
+	tpl.RegisterHelper("user", func(options *Options) string {
+		return Str(options.Context().Value(ctxKey{}))
+	})
+
+	execCtx := context.WithValue(context.Background(), ctxKey{}, "alice")
+
+	result, err := tpl.ExecContext(execCtx, nil)
+	if err != nil {
+		t.Fatalf("Failed to render template: %s", err)
+	}
+
+	if result != "alice" {
+		t.Errorf("Helper failed to get value from evaluation context: %q", result)
+	}
+
+	if result = tpl.MustExec(nil); result != "" {
+		t.Errorf("Helper should get a background evaluation context: %q", result)
+	}
+}
+
+func TestHelperMissing(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{title}} {{t "greeting" name=user}} {{user}} {{unknown}} {{upper (t "bye")}}`)
+	tpl.RegisterHelper("upper", strings.ToUpper)
+
+	ctx := map[string]string{"title": "Home", "user": "Jon"}
+
+	// no helperMissing registered
+	if output, expected := tpl.MustExec(ctx), "Home  Jon  "; output != expected {
+		t.Errorf("Unexpected output without helperMissing: %q, expected %q", output, expected)
+	}
+
+	var missing []string
+	tpl.RegisterHelper("helperMissing", func(options *Options) string {
+		missing = append(missing, options.Name())
+
+		return "[" + options.Name() + ":" + options.ParamStr(0) + options.HashStr("name") + "]"
+	})
+
+	if output, expected := tpl.MustExec(ctx), "Home [t:greetingJon] Jon [unknown:] [T:BYE]"; output != expected {
+		t.Errorf("Unexpected output with helperMissing: %q, expected %q", output, expected)
+	}
+
+	if expected := []string{"t", "unknown", "t"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Unexpected missing helpers: %v, expected %v", missing, expected)
+	}
+}
+
+func TestHelperMissingNilResult(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{foo 1}} {{#if (foo 1)}}yes{{else}}no{{/if}} {{bar}}`)
+	tpl.RegisterHelper("foo", func(n int) any { return nil })
+	tpl.RegisterHelper("helperMissing", func(options *Options) string {
+		return "[MISSING]"
+	})
+
+	// a helper returning nil is not missing
+	if output, expected := tpl.MustExec(nil), " no [MISSING]"; output != expected {
+		t.Errorf("Unexpected output: %q, expected %q", output, expected)
+	}
+}
+
+func TestBlockHelperMissing(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#user}}{{name}}{{/user}} {{#admin}}admin{{/admin}} {{#if user}}if{{/if}} {{#missing 1}}x{{/missing}}`)
+	tpl.RegisterHelper("blockHelperMissing", func(value any, options *Options) string {
+		return options.Name() + "=" + options.FnWith(value) + "(" + Str(value) + ")"
+	})
+
+	ctx := map[string]any{"user": map[string]string{"name": "Jon"}}
+
+	if output, expected := tpl.MustExec(ctx), "user=Jon(map[name:Jon]) admin=admin() if "; output != expected {
+		t.Errorf("Unexpected output with blockHelperMissing: %q, expected %q", output, expected)
+	}
+}
+
+func TestHelperRawContent(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{{{code lang=\"go\"}}}}\n  {{x}} {{{y}}}\n{{{{/code}}}} {{#code}}{{x}}{{/code}} {{code}}")
+	tpl.RegisterHelper("code", func(options *Options) SafeString {
+		return SafeString("<" + options.HashStr("lang") + ">" + options.RawContent() + "</>")
+	})
+
+	if output, expected := tpl.MustExec(map[string]string{"x": "1"}), "<go>\n  {{x}} {{{y}}}\n</> <></> <></>"; output != expected {
+		t.Errorf("Unexpected output: %q, expected %q", output, expected)
+	}
+}
+
+func TestHelperTrackIDs(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{ids user.name this.age "x" (upper user.name) @index ../up name=user.name lit=1}}`)
+	tpl.RegisterHelper("upper", strings.ToUpper)
+	tpl.RegisterHelper("ids", func(a, b, c, d, e, f any, options *Options) string {
+		var ids []string
+		for i := range options.Params() {
+			ids = append(ids, options.ParamID(i))
+		}
+
+		return strings.Join(ids, ",") + "|" + options.HashID("name") + "|" + options.HashID("lit") + "|" + options.HashID("missing")
+	})
+
+	if output := tpl.MustExec(nil); output != ",,,,,|||" {
+		t.Errorf("Ids must not be tracked by default, got: %q", output)
+	}
+
+	tpl.SetTrackIDs(true)
+
+	if output, expected := tpl.MustExec(nil), "user.name,age,,,@index,../up|user.name||"; output != expected {
+		t.Errorf("Unexpected ids: %q, expected %q", output, expected)
+	}
+}
+
+func TestHelperContextPath(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`[{{@contextPath}}]{{#with user}}[{{@contextPath}}]{{#each addresses}}[{{@contextPath}}]{{/each}}{{#each this.phones}}[{{@contextPath}}]{{/each}}{{/with}}[{{@contextPath}}]`)
+
+	ctx := map[string]any{
+		"user": map[string]any{
+			"addresses": []map[string]string{{"city": "Paris"}, {"city": "Lyon"}},
+			"phones":    map[string]string{"home": "42"},
+		},
+	}
+
+	if output := tpl.MustExec(ctx); output != "[][][][][][]" {
+		t.Errorf("Context path must not be set by default, got: %q", output)
+	}
+
+	tpl.SetTrackIDs(true)
+
+	if output, expected := tpl.MustExec(ctx), "[][user][user.addresses.0][user.addresses.1][user.phones.home][]"; output != expected {
+		t.Errorf("Unexpected context paths: %q, expected %q", output, expected)
+	}
+}
+
+func TestHelperStringParams(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{t greeting.hello name=user.name}} {{types greeting.hello "x" 1 true (t "y") @index n=2}}`)
+	tpl.RegisterHelper("t", func(key string, options *Options) string {
+		return key + ":" + options.HashStr("name")
+	})
+	tpl.RegisterHelper("types", func(a, b, c, d, e, f any, options *Options) string {
+		var types []string
+		for i := range options.Params() {
+			types = append(types, options.ParamType(i))
+		}
+
+		return strings.Join(types, ",") + "|" + options.HashType("n") + options.HashType("missing")
+	})
+
+	ctx := map[string]any{"greeting": map[string]string{"hello": "Hi"}, "user": map[string]string{"name": "Jon"}}
+
+	if output, expected := tpl.MustExec(ctx), "Hi:Jon ,,,,,|"; output != expected {
+		t.Errorf("Unexpected output in default mode: %q, expected %q", output, expected)
+	}
+
+	tpl.SetStringParams(true)
+
+	if output, expected := tpl.MustExec(ctx), "greeting.hello:user.name ID,STRING,NUMBER,BOOLEAN,SUBEXPRESSION,ID|NUMBER"; output != expected {
+		t.Errorf("Unexpected output in stringParams mode: %q, expected %q", output, expected)
+	}
+}
+
 func TestRemoveHelper(t *testing.T) {
 	RegisterHelper("testremovehelper", func() string { return "" })
-	if _, ok := helpers["testremovehelper"]; !ok {
+	if _, ok := defaultEnv.helpers["testremovehelper"]; !ok {
 		t.Error("Failed to register global helper")
 	}
 
 	RemoveHelper("testremovehelper")
-	if _, ok := helpers["testremovehelper"]; ok {
+	if _, ok := defaultEnv.helpers["testremovehelper"]; ok {
 		t.Error("Failed to remove global helper")
 	}
 }
@@ -665,7 +873,7 @@ func TestRegisterParamHelper(t *testing.T) {
 	pHelper := func(v reflect.Value) reflect.Value { return v }
 	RegisterParamHelper("test", pHelper)
 
-	gotFunc := findParamHelper("test")
+	gotFunc := defaultEnv.findParamHelper("test")
 	require.NotNil(t, gotFunc)
 
 	value := reflect.ValueOf("rick")
@@ -673,6 +881,6 @@ func TestRegisterParamHelper(t *testing.T) {
 	assert.Equal(t, value.String(), got.String())
 
 	RemoveParamHelper("test")
-	gotFunc = findParamHelper("test")
+	gotFunc = defaultEnv.findParamHelper("test")
 	assert.Nil(t, gotFunc)
 }
@@ -108,8 +108,22 @@ func (v *JSONVisitor) VisitBlock(node *ast.BlockStatement) any {
 	return nil
 }
 
-func (v *JSONVisitor) VisitPartial(node *ast.PartialStatement) any {
+func (v *JSONVisitor) VisitDecorator(node *ast.Decorator) any {
+	node.Expression.Accept(v)
+	return nil
+}
+
+func (v *JSONVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) any {
+	if node.Program != nil {
+		node.Program.Accept(v)
+	}
+	return nil
+}
 
+func (v *JSONVisitor) VisitPartial(node *ast.PartialStatement) any {
+	if node.Program != nil {
+		node.Program.Accept(v)
+	}
 	return nil
 }
 
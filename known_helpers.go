@@ -0,0 +1,194 @@
+package raymond
+
+import (
+	"fmt"
+
+	"github.com/yoinkai/raymond/v2/ast"
+)
+
+//
+// Known helpers
+//
+// In known helpers only mode, a template is rejected at parse time if it calls a helper that is not known: a mustache
+// or block with params or hash, or a subexpression, whose name is neither a helper of the environment nor declared with
+// Environment.SetKnownHelpers(). Other mustaches and blocks are then compiled as plain field lookups and sections.
+//
+
+// parseOptions represents template parsing options.
+type parseOptions struct {
+	// helpers known to exist at execution time, in addition to environment helpers
+	knownHelpers map[string]bool
+
+	// reject calls of unknown helpers
+	knownHelpersOnly bool
+}
+
+// clone returns a copy of parsing options
+func (opts parseOptions) clone() parseOptions {
+	result := opts
+
+	if opts.knownHelpers != nil {
+		result.knownHelpers = make(map[string]bool, len(opts.knownHelpers))
+		for name := range opts.knownHelpers {
+			result.knownHelpers[name] = true
+		}
+	}
+
+	return result
+}
+
+// isKnownHelper returns true if given helper is declared as known, or registered on template or its environment
+func (tpl *Template) isKnownHelper(name string) bool {
+	return tpl.parseOpts.knownHelpers[name] || (tpl.findHelper(name) != zero) || (tpl.env.findHelper(name) != zero)
+}
+
+// CheckHelpers returns an error if that template calls a helper that is not registered on it or its environment, nor
+// declared with Environment.SetKnownHelpers().
+//
+// Contrary to the known helpers only mode, that check can be done after helpers are registered on the template.
+func (tpl *Template) CheckHelpers() error {
+	return checkHelpers(tpl, tpl.program)
+}
+
+// checkHelpers returns an error if given program calls an unknown helper
+func checkHelpers(tpl *Template, program *ast.Program) (err error) {
+	defer errRecover(&err)
+
+	program.Accept(&helpersVisitor{tpl: tpl})
+
+	return nil
+}
+
+// helpersVisitor walks an AST to check that all called helpers are known
+type helpersVisitor struct {
+	tpl *Template
+}
+
+// checkExpression panics if given expression is a call of an unknown helper
+func (v *helpersVisitor) checkExpression(node *ast.Expression, isCall bool) {
+	if name := node.HelperName(); (name != "") && (isCall || (len(node.Params) > 0) || (node.Hash != nil)) {
+		if !v.tpl.isKnownHelper(name) {
+			panic(v.tpl.newParseError(node.Location().Pos, fmt.Sprintf("Unknown helper: %s", name)))
+		}
+	}
+
+	node.Accept(v)
+}
+
+// acceptParams visits given params and hash
+func (v *helpersVisitor) acceptParams(params []ast.Node, hash *ast.Hash) {
+	for _, param := range params {
+		param.Accept(v)
+	}
+
+	if hash != nil {
+		hash.Accept(v)
+	}
+}
+
+// VisitProgram implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitProgram(node *ast.Program) any {
+	for _, n := range node.Body {
+		n.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitMustache implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitMustache(node *ast.MustacheStatement) any {
+	v.checkExpression(node.Expression, false)
+
+	return nil
+}
+
+// VisitBlock implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitBlock(node *ast.BlockStatement) any {
+	v.checkExpression(node.Expression, false)
+
+	for _, program := range []*ast.Program{node.Program, node.Inverse} {
+		if program != nil {
+			program.Accept(v)
+		}
+	}
+
+	return nil
+}
+
+// VisitPartial implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitPartial(node *ast.PartialStatement) any {
+	node.Name.Accept(v)
+	v.acceptParams(node.Params, node.Hash)
+
+	if node.Program != nil {
+		node.Program.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitDecorator implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitDecorator(node *ast.Decorator) any {
+	node.Expression.Accept(v)
+
+	return nil
+}
+
+// VisitDecoratorBlock implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitDecoratorBlock(node *ast.DecoratorBlock) any {
+	node.Expression.Accept(v)
+
+	if node.Program != nil {
+		node.Program.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitContent implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitContent(node *ast.ContentStatement) any { return nil }
+
+// VisitComment implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitComment(node *ast.CommentStatement) any { return nil }
+
+// VisitExpression implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitExpression(node *ast.Expression) any {
+	v.acceptParams(node.Params, node.Hash)
+
+	return nil
+}
+
+// VisitSubExpression implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitSubExpression(node *ast.SubExpression) any {
+	v.checkExpression(node.Expression, true)
+
+	return nil
+}
+
+// VisitPath implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitPath(node *ast.PathExpression) any { return nil }
+
+// VisitString implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitString(node *ast.StringLiteral) any { return nil }
+
+// VisitBoolean implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitBoolean(node *ast.BooleanLiteral) any { return nil }
+
+// VisitNumber implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitNumber(node *ast.NumberLiteral) any { return nil }
+
+// VisitHash implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitHash(node *ast.Hash) any {
+	for _, pair := range node.Pairs {
+		pair.Accept(v)
+	}
+
+	return nil
+}
+
+// VisitHashPair implements corresponding Visitor interface method
+func (v *helpersVisitor) VisitHashPair(node *ast.HashPair) any {
+	node.Val.Accept(v)
+
+	return nil
+}
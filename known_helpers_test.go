@@ -0,0 +1,103 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var knownHelpersTests = []struct {
+	name   string
+	input  string
+	data   any
+	output string
+}{
+	{"known helper", `{{hello}}`, nil, "foo"},
+	{"known helper with params", `{{echo "foo"}}`, nil, "foo"},
+	{"unknown helper passed as field", `{{typeof hello}}`, map[string]string{"hello": "world"}, "string"},
+	{"builtin helpers", `{{#unless foo}}bar{{/unless}}`, nil, "bar"},
+	{"field lookup", `{{foo}}`, map[string]string{"foo": "bar"}, "bar"},
+	{"conditional blocks", `{{#foo}}bar{{/foo}}`, map[string]string{"foo": "baz"}, "bar"},
+	{"invert blocks", `{{^foo}}bar{{/foo}}`, map[string]bool{"foo": false}, "bar"},
+	{"subexpression", `{{echo (echo "foo")}}`, nil, "foo"},
+}
+
+func TestKnownHelpersOnly(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.RegisterHelper("echo", func(str string) string { return str })
+	env.RegisterHelper("typeof", func(val any) string { return fmt.Sprintf("%T", val) })
+	env.SetKnownHelpers("hello")
+	env.SetKnownHelpersOnly(true)
+
+	for _, test := range knownHelpersTests {
+		tpl, err := env.Parse(test.input)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected parse error: %s", test.name, err)
+			continue
+		}
+
+		// not known at parse time, so it is evaluated as a field
+		tpl.RegisterHelper("hello", func() string { return "foo" })
+		tpl.RegisterHelper("foo", func() string { return "helper" })
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+var knownHelpersErrors = []struct {
+	name  string
+	input string
+	err   string
+}{
+	{"unknown helper", `{{formatDte x}}`, "Parse error at 1:1:\nUnknown helper: formatDte"},
+	{"unknown helper with hash", "\n{{format x=1}}", "Parse error at 2:1:\nUnknown helper: format"},
+	{"unknown block helper", "\n\n{{#list items}}{{/list}}", "Parse error at 3:1:\nUnknown helper: list"},
+	{"unknown subexpression", `{{echo (upper x)}}`, "Unknown helper: upper"},
+	{"unknown helper in block", `{{#each items}}{{t "x"}}{{/each}}`, "Unknown helper: t"},
+	{"unknown helper in partial params", `{{> p (t "x")}}`, "Unknown helper: t"},
+	{"unknown helper in partial block", `{{#> p}}{{t "x"}}{{/p}}`, "Unknown helper: t"},
+	{"unknown helper in inline partial", `{{#*inline "p"}}{{t "x"}}{{/inline}}`, "Unknown helper: t"},
+}
+
+func TestKnownHelpersOnlyErrors(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.RegisterHelper("echo", func(str string) string { return str })
+	env.SetKnownHelpersOnly(true)
+
+	for _, test := range knownHelpersErrors {
+		_, err := env.Parse(test.input)
+		if err == nil {
+			t.Errorf("Test '%s' failed - Error expected", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test '%s' failed - Incorrect error returned\nexpected\n\t%q\ngot\n\t%q", test.name, test.err, err)
+		}
+	}
+
+	// not enabled by default
+	if _, err := Parse(`{{formatDte x}}`); err != nil {
+		t.Errorf("Unexpected error without known helpers only mode: %s", err)
+	}
+}
+
+func TestCheckHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#if ok}}\n{{formatDate date}}{{/if}}")
+	if err := tpl.CheckHelpers(); (err == nil) || !strings.Contains(err.Error(), "Parse error at 2:1:\nUnknown helper: formatDate") {
+		t.Errorf("Unexpected error for an unknown helper: %v", err)
+	}
+
+	tpl.RegisterHelper("formatDate", func(date string) string { return date })
+	if err := tpl.CheckHelpers(); err != nil {
+		t.Errorf("Unexpected error for a template helper: %s", err)
+	}
+}
@@ -0,0 +1,199 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/yoinkai/raymond/v2/ast"
+)
+
+//
+// Layouts
+//
+// The extend, block and content helpers implement layout inheritance, in the style of handlebars-layouts:
+//
+//	{{#extend "layout"}}
+//	  {{#content "title" mode="append"}} - Home{{/content}}
+//	{{/extend}}
+//
+// The layout is a partial that defines overridable regions with {{#block "title"}}My Site{{/block}}.
+//
+// Those helpers are not registered by default, as they would shadow the `block` and `content` fields of existing
+// templates.
+//
+
+// RegisterLayoutHelpers registers the extend, block and content layout helpers in the default environment.
+func RegisterLayoutHelpers() {
+	defaultEnv.RegisterLayoutHelpers()
+}
+
+// layoutHelpers returns the layout helpers by name
+func layoutHelpers() map[string]any {
+	return map[string]any{
+		"extend":  extendHelper,
+		"block":   blockHelper,
+		"content": contentHelper,
+	}
+}
+
+// layoutFrame holds the contents defined in an {{#extend}} block
+type layoutFrame struct {
+	contents map[string][]*layoutContent
+}
+
+// layoutContent is a content defined with {{#content}}, that overrides a layout block
+type layoutContent struct {
+	mode    string
+	program *ast.Program
+
+	// template defining that content, with its compiled programs and mustaches escapers
+	tpl          *Template
+	programs     compiledPrograms
+	htmlEscapers htmlEscapers
+}
+
+// content modes
+const (
+	contentReplace = "replace"
+	contentAppend  = "append"
+	contentPrepend = "prepend"
+)
+
+// pushLayout pushes a new layout frame to stack
+func (v *evalVisitor) pushLayout() *layoutFrame {
+	frame := &layoutFrame{contents: make(map[string][]*layoutContent)}
+	v.layouts = append(v.layouts, frame)
+
+	return frame
+}
+
+// popLayout pops last layout frame from stack
+func (v *evalVisitor) popLayout() {
+	if len(v.layouts) == 0 {
+		return
+	}
+
+	v.layouts = v.layouts[:len(v.layouts)-1]
+}
+
+// layoutContents returns the contents overriding given block, in application order
+//
+// Contents of the innermost layout frame are applied first, so that contents of a page override those of the layouts
+// it extends.
+func (v *evalVisitor) layoutContents(name string) []*layoutContent {
+	var result []*layoutContent
+
+	for i := len(v.layouts) - 1; i >= 0; i-- {
+		result = append(result, v.layouts[i].contents[name]...)
+	}
+
+	return result
+}
+
+// evalLayoutContent evaluates given content with current context
+func (v *evalVisitor) evalLayoutContent(content *layoutContent) string {
+	prevSrcTpl, prevPrograms, prevEscapers := v.srcTpl, v.programs, v.htmlEscapers
+	v.srcTpl, v.programs, v.htmlEscapers = content.tpl, content.programs, content.htmlEscapers
+
+	defer func() { v.srcTpl, v.programs, v.htmlEscapers = prevSrcTpl, prevPrograms, prevEscapers }()
+
+	return v.capture(func() { content.program.Accept(v) })
+}
+
+// isBlock returns true if helper is called as a block helper
+func (options *Options) isBlock() bool {
+	block := options.eval.curBlock()
+
+	return (block != nil) && (block.Expression == options.eval.curExpr())
+}
+
+// #extend block helper
+//
+// Evaluates the contents defined in block, then renders the layout partial with current context, or with hash
+// arguments if any.
+func extendHelper(name string, options *Options) any {
+	v := options.eval
+
+	p := v.findPartial(name)
+	if p == nil {
+		v.errorf("Partial not found: %s", name)
+	}
+
+	v.pushLayout()
+	defer v.popLayout()
+
+	// collect contents, block output is ignored
+	if options.isBlock() {
+		options.Fn()
+	}
+
+	ctx := zero
+	if len(options.Hash()) > 0 {
+		ctx = reflect.ValueOf(options.Hash())
+	}
+
+	v.writePartial(p, ctx, "")
+
+	return ""
+}
+
+// #block block helper
+//
+// Renders block content, overridden by the contents defined for that block name.
+func blockHelper(name string, options *Options) any {
+	result := ""
+	if options.isBlock() {
+		result = options.Fn()
+	}
+
+	for _, content := range options.eval.layoutContents(name) {
+		switch content.mode {
+		case contentAppend:
+			result += options.eval.evalLayoutContent(content)
+		case contentPrepend:
+			result = options.eval.evalLayoutContent(content) + result
+		default:
+			result = options.eval.evalLayoutContent(content)
+		}
+	}
+
+	return SafeString(result)
+}
+
+// #content block helper
+//
+// Defines the content of a layout block. Called without a block, it returns true if a content is defined for that
+// block name.
+func contentHelper(name string, options *Options) any {
+	v := options.eval
+
+	if !options.isBlock() {
+		return len(v.layoutContents(name)) > 0
+	}
+
+	program := v.curBlock().Program
+	if (program == nil) || (len(v.layouts) == 0) {
+		// no content, or not in an extend block
+		return ""
+	}
+
+	mode := strings.ToLower(options.HashStr("mode"))
+	switch mode {
+	case "":
+		mode = contentReplace
+	case contentReplace, contentAppend, contentPrepend:
+	default:
+		v.errorf("Unknown content mode: %s", mode)
+	}
+
+	frame := v.layouts[len(v.layouts)-1]
+	frame.contents[name] = append(frame.contents[name], &layoutContent{
+		mode:         mode,
+		program:      program,
+		tpl:          v.srcTpl,
+		programs:     v.programs,
+		htmlEscapers: v.htmlEscapers,
+	})
+
+	return ""
+}
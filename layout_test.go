@@ -0,0 +1,149 @@
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+var layoutPartials = map[string]string{
+	"base": `<title>{{#block "title"}}Site{{/block}}</title>` +
+		`<main>{{#block "main"}}empty{{/block}}</main>` +
+		`{{#if (content "footer")}}<footer>{{block "footer"}}</footer>{{/if}}`,
+	"twoColumns": `{{#extend "base"}}` +
+		`{{#content "title" mode="append"}} - Columns{{/content}}` +
+		`{{#content "main"}}<left>{{#block "left"}}{{/block}}</left><right>{{#block "right"}}right{{/block}}</right>{{/content}}` +
+		`{{/extend}}`,
+}
+
+var layoutTests = []struct {
+	name   string
+	input  string
+	data   any
+	output string
+}{
+	{
+		"layout without overrides",
+		`{{#extend "base"}}{{/extend}}`,
+		nil,
+		"<title>Site</title><main>empty</main>",
+	},
+	{
+		"replace content",
+		`{{#extend "base"}}{{#content "main"}}Hello {{name}}{{/content}}{{/extend}}`,
+		map[string]string{"name": "Jon"},
+		"<title>Site</title><main>Hello Jon</main>",
+	},
+	{
+		"explicit replace mode",
+		`{{#extend "base"}}{{#content "title" mode="replace"}}Home{{/content}}{{/extend}}`,
+		nil,
+		"<title>Home</title><main>empty</main>",
+	},
+	{
+		"append and prepend contents",
+		`{{#extend "base"}}{{#content "title" mode="append"}} - Home{{/content}}{{#content "main" mode="PREPEND"}}first {{/content}}{{/extend}}`,
+		nil,
+		"<title>Site - Home</title><main>first empty</main>",
+	},
+	{
+		"content output is ignored outside of blocks",
+		`{{#extend "base"}}ignored{{#content "main"}}main{{/content}}ignored{{/extend}}`,
+		nil,
+		"<title>Site</title><main>main</main>",
+	},
+	{
+		"content getter",
+		`{{#extend "base"}}{{#content "footer"}}(c) {{year}}{{/content}}{{/extend}}`,
+		map[string]int{"year": 2024},
+		"<title>Site</title><main>empty</main><footer>(c) 2024</footer>",
+	},
+	{
+		"nested layouts",
+		`{{#extend "twoColumns"}}{{#content "left"}}{{name}}{{/content}}{{/extend}}`,
+		map[string]string{"name": "Jon"},
+		"<title>Site - Columns</title><main><left>Jon</left><right>right</right></main>",
+	},
+	{
+		"page contents override nested layouts contents",
+		`{{#extend "twoColumns"}}{{#content "title"}}Page{{/content}}{{#content "title" mode="append"}}!{{/content}}{{/extend}}`,
+		nil,
+		"<title>Page!</title><main><left></left><right>right</right></main>",
+	},
+	{
+		"layout context from hash",
+		`{{#extend "hashed" title="Home"}}{{#content "main"}}{{name}}{{/content}}{{/extend}}`,
+		map[string]string{"name": "Jon"},
+		"<title>Home</title><main>Jon</main>",
+	},
+	{
+		"contents evaluated with block context",
+		`{{#extend "list"}}{{#content "item"}}<li>{{.}}</li>{{/content}}{{/extend}}`,
+		map[string][]string{"items": {"a", "b"}},
+		"<ul><li>a</li><li>b</li></ul>",
+	},
+	{
+		"block outside of a layout",
+		`{{#block "main"}}default{{/block}}`,
+		nil,
+		"default",
+	},
+	{
+		"escaped content",
+		`{{#extend "base"}}{{#content "main"}}{{name}}{{/content}}{{/extend}}`,
+		map[string]string{"name": "<b>"},
+		"<title>Site</title><main>&lt;b&gt;</main>",
+	},
+}
+
+func TestLayouts(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnvironment()
+	env.RegisterLayoutHelpers()
+	env.RegisterPartials(layoutPartials)
+	env.RegisterPartial("hashed", `<title>{{title}}</title><main>{{#block "main"}}{{/block}}</main>`)
+	env.RegisterPartial("list", `<ul>{{#each items}}{{#block "item"}}{{/block}}{{/each}}</ul>`)
+
+	for _, test := range layoutTests {
+		tpl, err := env.Parse(test.input)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Failed to parse template: %s", test.name, err)
+			continue
+		}
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#extend "missing"}}{{/extend}}`)
+	tpl.RegisterLayoutHelpers()
+
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Partial not found: missing") {
+		t.Errorf("Unexpected error for a missing layout: %v", err)
+	}
+
+	tpl = MustParse(`{{#extend "layout"}}{{#content "main" mode="merge"}}{{/content}}{{/extend}}`)
+	tpl.RegisterLayoutHelpers()
+	tpl.RegisterPartial("layout", "")
+
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "Unknown content mode: merge") {
+		t.Errorf("Unexpected error for an unknown content mode: %v", err)
+	}
+}
+
+func TestLayoutHelpersNotRegistered(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{block}} {{content}}`)
+	if output := tpl.MustExec(map[string]string{"block": "b", "content": "c"}); output != "b c" {
+		t.Errorf("Layout helpers must not be registered by default, got: %q", output)
+	}
+}
@@ -40,6 +40,9 @@ type Lexer struct {
 	width int // size of last rune scanned from input string
 	start int // start position of the token we are scanning
 
+	linePos int // byte position up to which lines and columns were counted
+	lineCol int // number of runes between current line start and linePos
+
 	// the shameful contextual properties needed because `nextFunc` is not enough
 	closeComment *regexp.Regexp // regexp to scan close of current comment
 	rawBlock     bool           // are we parsing a raw block content ?
@@ -63,9 +66,12 @@ var (
 	rOpenEndRawLookAhead = regexp.MustCompile(`\{\{\{\{/`)
 	rOpenUnescaped       = regexp.MustCompile(`^\{\{~?\{`)
 	rCloseUnescaped      = regexp.MustCompile(`^\}~?\}\}`)
+	rOpenPartialBlock    = regexp.MustCompile(`^\{\{~?#>`)
+	rOpenDecoratorBlock  = regexp.MustCompile(`^\{\{~?#\*`)
 	rOpenBlock           = regexp.MustCompile(`^\{\{~?#`)
 	rOpenEndBlock        = regexp.MustCompile(`^\{\{~?/`)
 	rOpenPartial         = regexp.MustCompile(`^\{\{~?>`)
+	rOpenDecorator       = regexp.MustCompile(`^\{\{~?\*`)
 	// {{^}} or {{else}}
 	rInverse          = regexp.MustCompile(`^(\{\{~?\^\s*~?\}\}|\{\{~?\s*else\s*~?\}\})`)
 	rOpenInverse      = regexp.MustCompile(`^\{\{~?\^`)
@@ -153,13 +159,36 @@ func (l *Lexer) next() rune {
 }
 
 func (l *Lexer) produce(kind TokenKind, val string) {
-	l.tokens <- Token{kind, val, l.start, l.line}
+	l.produceSpan(kind, val, l.start, l.pos)
+}
+
+// produceSpan emits a new token with given value, that spans input from start to end byte positions
+func (l *Lexer) produceSpan(kind TokenKind, val string, start int, end int) {
+	line, column := l.position(start)
+
+	l.tokens <- Token{Kind: kind, Val: val, Pos: start, End: end, Line: line, Column: column}
 
 	// scanning a new token
 	l.start = l.pos
+}
 
-	// update line number
-	l.line += strings.Count(val, "\n")
+// position returns the line and the 1-based column in runes of given byte position
+//
+// Positions must be given in increasing order, so that each rune of input is counted once.
+func (l *Lexer) position(pos int) (int, int) {
+	for l.linePos < pos {
+		r, w := utf8.DecodeRuneInString(l.input[l.linePos:])
+		l.linePos += w
+
+		if r == '\n' {
+			l.line++
+			l.lineCol = 0
+		} else {
+			l.lineCol++
+		}
+	}
+
+	return l.line, l.lineCol + 1
 }
 
 // emit emits a new scanned token
@@ -181,7 +210,8 @@ func (l *Lexer) emitString(delimiter rune) {
 	// replace escaped delimiters
 	str = strings.Replace(str, "\\"+string(delimiter), string(delimiter), -1)
 
-	l.produce(TokenString, str)
+	// token spans delimiters
+	l.produceSpan(TokenString, str, l.start-1, l.pos+1)
 }
 
 // peek returns but does not consume the next character in the input
@@ -224,7 +254,9 @@ func (l *Lexer) acceptRun(valid string) {
 
 // errorf emits an error token
 func (l *Lexer) errorf(format string, args ...any) lexFunc {
-	l.tokens <- Token{TokenError, fmt.Sprintf(format, args...), l.start, l.line}
+	line, column := l.position(l.start)
+
+	l.tokens <- Token{Kind: TokenError, Val: fmt.Sprintf(format, args...), Pos: l.start, End: l.pos, Line: line, Column: column}
 	return nil
 }
 
@@ -343,12 +375,18 @@ func lexOpenMustache(l *Lexer) lexFunc {
 		l.rawBlock = true
 	} else if str = l.findRegexp(rOpenUnescaped); str != "" {
 		tok = TokenOpenUnescaped
+	} else if str = l.findRegexp(rOpenPartialBlock); str != "" {
+		tok = TokenOpenPartialBlock
+	} else if str = l.findRegexp(rOpenDecoratorBlock); str != "" {
+		tok = TokenOpenDecoratorBlock
 	} else if str = l.findRegexp(rOpenBlock); str != "" {
 		tok = TokenOpenBlock
 	} else if str = l.findRegexp(rOpenEndBlock); str != "" {
 		tok = TokenOpenEndBlock
 	} else if str = l.findRegexp(rOpenPartial); str != "" {
 		tok = TokenOpenPartial
+	} else if str = l.findRegexp(rOpenDecorator); str != "" {
+		tok = TokenOpenDecorator
 	} else if str = l.findRegexp(rInverse); str != "" {
 		tok = TokenInverse
 		nextFunc = lexContent
@@ -12,40 +12,43 @@ type lexTest struct {
 }
 
 // helpers
-func tokContent(val string) Token { return Token{TokenContent, val, 0, 1} }
-func tokID(val string) Token      { return Token{TokenID, val, 0, 1} }
-func tokSep(val string) Token     { return Token{TokenSep, val, 0, 1} }
-func tokString(val string) Token  { return Token{TokenString, val, 0, 1} }
-func tokNumber(val string) Token  { return Token{TokenNumber, val, 0, 1} }
-func tokInverse(val string) Token { return Token{TokenInverse, val, 0, 1} }
-func tokBool(val string) Token    { return Token{TokenBoolean, val, 0, 1} }
-func tokError(val string) Token   { return Token{TokenError, val, 0, 1} }
-func tokComment(val string) Token { return Token{TokenComment, val, 0, 1} }
-
-var tokEOF = Token{TokenEOF, "", 0, 1}
-var tokEquals = Token{TokenEquals, "=", 0, 1}
-var tokData = Token{TokenData, "@", 0, 1}
-var tokOpen = Token{TokenOpen, "{{", 0, 1}
-var tokOpenAmp = Token{TokenOpen, "{{&", 0, 1}
-var tokOpenPartial = Token{TokenOpenPartial, "{{>", 0, 1}
-var tokClose = Token{TokenClose, "}}", 0, 1}
-var tokOpenStrip = Token{TokenOpen, "{{~", 0, 1}
-var tokCloseStrip = Token{TokenClose, "~}}", 0, 1}
-var tokOpenUnescaped = Token{TokenOpenUnescaped, "{{{", 0, 1}
-var tokCloseUnescaped = Token{TokenCloseUnescaped, "}}}", 0, 1}
-var tokOpenUnescapedStrip = Token{TokenOpenUnescaped, "{{~{", 0, 1}
-var tokCloseUnescapedStrip = Token{TokenCloseUnescaped, "}~}}", 0, 1}
-var tokOpenBlock = Token{TokenOpenBlock, "{{#", 0, 1}
-var tokOpenEndBlock = Token{TokenOpenEndBlock, "{{/", 0, 1}
-var tokOpenInverse = Token{TokenOpenInverse, "{{^", 0, 1}
-var tokOpenInverseChain = Token{TokenOpenInverseChain, "{{else", 0, 1}
-var tokOpenSexpr = Token{TokenOpenSexpr, "(", 0, 1}
-var tokCloseSexpr = Token{TokenCloseSexpr, ")", 0, 1}
-var tokOpenBlockParams = Token{TokenOpenBlockParams, "as |", 0, 1}
-var tokCloseBlockParams = Token{TokenCloseBlockParams, "|", 0, 1}
-var tokOpenRawBlock = Token{TokenOpenRawBlock, "{{{{", 0, 1}
-var tokCloseRawBlock = Token{TokenCloseRawBlock, "}}}}", 0, 1}
-var tokOpenEndRawBlock = Token{TokenOpenEndRawBlock, "{{{{/", 0, 1}
+func tokContent(val string) Token { return Token{Kind: TokenContent, Val: val, Line: 1} }
+func tokID(val string) Token      { return Token{Kind: TokenID, Val: val, Line: 1} }
+func tokSep(val string) Token     { return Token{Kind: TokenSep, Val: val, Line: 1} }
+func tokString(val string) Token  { return Token{Kind: TokenString, Val: val, Line: 1} }
+func tokNumber(val string) Token  { return Token{Kind: TokenNumber, Val: val, Line: 1} }
+func tokInverse(val string) Token { return Token{Kind: TokenInverse, Val: val, Line: 1} }
+func tokBool(val string) Token    { return Token{Kind: TokenBoolean, Val: val, Line: 1} }
+func tokError(val string) Token   { return Token{Kind: TokenError, Val: val, Line: 1} }
+func tokComment(val string) Token { return Token{Kind: TokenComment, Val: val, Line: 1} }
+
+var tokEOF = Token{Kind: TokenEOF, Val: "", Line: 1}
+var tokEquals = Token{Kind: TokenEquals, Val: "=", Line: 1}
+var tokData = Token{Kind: TokenData, Val: "@", Line: 1}
+var tokOpen = Token{Kind: TokenOpen, Val: "{{", Line: 1}
+var tokOpenAmp = Token{Kind: TokenOpen, Val: "{{&", Line: 1}
+var tokOpenPartial = Token{Kind: TokenOpenPartial, Val: "{{>", Line: 1}
+var tokClose = Token{Kind: TokenClose, Val: "}}", Line: 1}
+var tokOpenStrip = Token{Kind: TokenOpen, Val: "{{~", Line: 1}
+var tokCloseStrip = Token{Kind: TokenClose, Val: "~}}", Line: 1}
+var tokOpenUnescaped = Token{Kind: TokenOpenUnescaped, Val: "{{{", Line: 1}
+var tokCloseUnescaped = Token{Kind: TokenCloseUnescaped, Val: "}}}", Line: 1}
+var tokOpenUnescapedStrip = Token{Kind: TokenOpenUnescaped, Val: "{{~{", Line: 1}
+var tokCloseUnescapedStrip = Token{Kind: TokenCloseUnescaped, Val: "}~}}", Line: 1}
+var tokOpenBlock = Token{Kind: TokenOpenBlock, Val: "{{#", Line: 1}
+var tokOpenEndBlock = Token{Kind: TokenOpenEndBlock, Val: "{{/", Line: 1}
+var tokOpenPartialBlock = Token{Kind: TokenOpenPartialBlock, Val: "{{#>", Line: 1}
+var tokOpenDecoratorBlock = Token{Kind: TokenOpenDecoratorBlock, Val: "{{#*", Line: 1}
+var tokOpenDecorator = Token{Kind: TokenOpenDecorator, Val: "{{*", Line: 1}
+var tokOpenInverse = Token{Kind: TokenOpenInverse, Val: "{{^", Line: 1}
+var tokOpenInverseChain = Token{Kind: TokenOpenInverseChain, Val: "{{else", Line: 1}
+var tokOpenSexpr = Token{Kind: TokenOpenSexpr, Val: "(", Line: 1}
+var tokCloseSexpr = Token{Kind: TokenCloseSexpr, Val: ")", Line: 1}
+var tokOpenBlockParams = Token{Kind: TokenOpenBlockParams, Val: "as |", Line: 1}
+var tokCloseBlockParams = Token{Kind: TokenCloseBlockParams, Val: "|", Line: 1}
+var tokOpenRawBlock = Token{Kind: TokenOpenRawBlock, Val: "{{{{", Line: 1}
+var tokCloseRawBlock = Token{Kind: TokenCloseRawBlock, Val: "}}}}", Line: 1}
+var tokOpenEndRawBlock = Token{Kind: TokenOpenEndRawBlock, Val: "{{{{/", Line: 1}
 
 var lexTests = []lexTest{
 	{"empty", "", []Token{tokEOF}},
@@ -262,6 +265,21 @@ var lexTests = []lexTest{
 		`{{#foo}}content{{/foo}}`,
 		[]Token{tokOpenBlock, tokID("foo"), tokClose, tokContent("content"), tokOpenEndBlock, tokID("foo"), tokClose, tokEOF},
 	},
+	{
+		`tokenizes open partial blocks as OPEN_PARTIAL_BLOCK`,
+		`{{#> foo}}content{{/foo}}`,
+		[]Token{tokOpenPartialBlock, tokID("foo"), tokClose, tokContent("content"), tokOpenEndBlock, tokID("foo"), tokClose, tokEOF},
+	},
+	{
+		`tokenizes open decorator blocks as OPEN_DECORATOR_BLOCK`,
+		`{{#*inline "foo"}}content{{/inline}}`,
+		[]Token{tokOpenDecoratorBlock, tokID("inline"), tokString("foo"), tokClose, tokContent("content"), tokOpenEndBlock, tokID("inline"), tokClose, tokEOF},
+	},
+	{
+		`tokenizes decorators as OPEN_DECORATOR`,
+		`{{* foo bar}}`,
+		[]Token{tokOpenDecorator, tokID("foo"), tokID("bar"), tokClose, tokEOF},
+	},
 	{
 		`tokenizes inverse sections as "INVERSE"`,
 		`{{^}}`,
@@ -514,6 +532,69 @@ func TestLexer(t *testing.T) {
 	}
 }
 
+func TestLexerPositions(t *testing.T) {
+	t.Parallel()
+
+	// columns are counted in runes, and lines include newlines between mustache tokens
+	tokens := Collect("été {{foo\n  \"bar\" }}")
+
+	expected := []struct {
+		kind                   TokenKind
+		pos, end, line, column int
+	}{
+		{TokenContent, 0, 6, 1, 1},
+		{TokenOpen, 6, 8, 1, 5},
+		{TokenID, 8, 11, 1, 7},
+		{TokenString, 14, 19, 2, 3},
+		{TokenClose, 20, 22, 2, 9},
+		{TokenEOF, 22, 22, 2, 11},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("Unexpected tokens: %v", tokens)
+	}
+
+	for i, tok := range tokens {
+		exp := expected[i]
+		if (tok.Kind != exp.kind) || (tok.Pos != exp.pos) || (tok.End != exp.end) || (tok.Line != exp.line) || (tok.Column != exp.column) {
+			t.Errorf("Unexpected token %d: %s %d-%d %d:%d", i, tok, tok.Pos, tok.End, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestLexerStringPositions(t *testing.T) {
+	t.Parallel()
+
+	// string tokens span their delimiters, while their value excludes them
+	tokens := Collect(`{{foo "a\"b" 'é'}}`)
+
+	expected := []struct {
+		val                    string
+		pos, end, line, column int
+	}{
+		{`a"b`, 6, 12, 1, 7},
+		{"é", 13, 17, 1, 14},
+	}
+
+	var strs []Token
+	for _, tok := range tokens {
+		if tok.Kind == TokenString {
+			strs = append(strs, tok)
+		}
+	}
+
+	if len(strs) != len(expected) {
+		t.Fatalf("Unexpected tokens: %v", tokens)
+	}
+
+	for i, tok := range strs {
+		exp := expected[i]
+		if (tok.Val != exp.val) || (tok.Pos != exp.pos) || (tok.End != exp.end) || (tok.Line != exp.line) || (tok.Column != exp.column) {
+			t.Errorf("Unexpected string token %d: %s %d-%d %d:%d", i, tok, tok.Pos, tok.End, tok.Line, tok.Column)
+		}
+	}
+}
+
 // @todo Test errors:
 //   `{{{{raw foo`
 
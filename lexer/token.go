@@ -98,6 +98,19 @@ const (
 
 	// TokenBoolean is the BOOLEAN token
 	TokenBoolean
+
+	//
+	// Mustache delimiters, appended to keep the values of previous kinds
+	//
+
+	// TokenOpenPartialBlock is the OPEN_PARTIAL_BLOCK token
+	TokenOpenPartialBlock
+
+	// TokenOpenDecoratorBlock is the OPEN_BLOCK token of a decorator block
+	TokenOpenDecoratorBlock
+
+	// TokenOpenDecorator is the OPEN token of a decorator
+	TokenOpenDecorator
 )
 
 const (
@@ -116,40 +129,46 @@ type Token struct {
 	Kind TokenKind // Token kind
 	Val  string    // Token value
 
-	Pos  int // Byte position in input string
+	Pos  int // Byte position in input string, string tokens include their delimiters
+	End  int // Byte position following the token in input string
 	Line int // Line number in input string
+
+	Column int // 1-based column in runes
 }
 
 // tokenName permits to display token name given token type
 var tokenName = map[TokenKind]string{
-	TokenError:            "Error",
-	TokenEOF:              "EOF",
-	TokenContent:          "Content",
-	TokenComment:          "Comment",
-	TokenOpen:             "Open",
-	TokenClose:            "Close",
-	TokenOpenUnescaped:    "OpenUnescaped",
-	TokenCloseUnescaped:   "CloseUnescaped",
-	TokenOpenBlock:        "OpenBlock",
-	TokenOpenEndBlock:     "OpenEndBlock",
-	TokenOpenRawBlock:     "OpenRawBlock",
-	TokenCloseRawBlock:    "CloseRawBlock",
-	TokenOpenEndRawBlock:  "OpenEndRawBlock",
-	TokenOpenBlockParams:  "OpenBlockParams",
-	TokenCloseBlockParams: "CloseBlockParams",
-	TokenInverse:          "Inverse",
-	TokenOpenInverse:      "OpenInverse",
-	TokenOpenInverseChain: "OpenInverseChain",
-	TokenOpenPartial:      "OpenPartial",
-	TokenOpenSexpr:        "OpenSexpr",
-	TokenCloseSexpr:       "CloseSexpr",
-	TokenID:               "ID",
-	TokenEquals:           "Equals",
-	TokenString:           "String",
-	TokenNumber:           "Number",
-	TokenBoolean:          "Boolean",
-	TokenData:             "Data",
-	TokenSep:              "Sep",
+	TokenError:              "Error",
+	TokenEOF:                "EOF",
+	TokenContent:            "Content",
+	TokenComment:            "Comment",
+	TokenOpen:               "Open",
+	TokenClose:              "Close",
+	TokenOpenUnescaped:      "OpenUnescaped",
+	TokenCloseUnescaped:     "CloseUnescaped",
+	TokenOpenBlock:          "OpenBlock",
+	TokenOpenEndBlock:       "OpenEndBlock",
+	TokenOpenRawBlock:       "OpenRawBlock",
+	TokenCloseRawBlock:      "CloseRawBlock",
+	TokenOpenEndRawBlock:    "OpenEndRawBlock",
+	TokenOpenBlockParams:    "OpenBlockParams",
+	TokenCloseBlockParams:   "CloseBlockParams",
+	TokenInverse:            "Inverse",
+	TokenOpenInverse:        "OpenInverse",
+	TokenOpenInverseChain:   "OpenInverseChain",
+	TokenOpenPartial:        "OpenPartial",
+	TokenOpenSexpr:          "OpenSexpr",
+	TokenCloseSexpr:         "CloseSexpr",
+	TokenID:                 "ID",
+	TokenEquals:             "Equals",
+	TokenString:             "String",
+	TokenNumber:             "Number",
+	TokenBoolean:            "Boolean",
+	TokenData:               "Data",
+	TokenSep:                "Sep",
+	TokenOpenPartialBlock:   "OpenPartialBlock",
+	TokenOpenDecoratorBlock: "OpenDecoratorBlock",
+	TokenOpenDecorator:      "OpenDecorator",
 }
 
 // String returns the token kind string representation for debugging.
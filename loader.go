@@ -0,0 +1,185 @@
+package raymond
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Loader loads all templates of a filesystem, and reloads them when their files change.
+//
+// Each template can call all other templates as partials. Templates are named after their file path without extension,
+// like `emails/header` for `emails/header.hbs`.
+//
+// Reloads are atomic: a template returned by Template() is never modified, so evaluations in progress keep using the
+// previous version of a reloaded template.
+//
+// Helpers, partials and decorators used by loaded templates must be registered on the loader environment, returned by
+// Environment(), as those registered on a returned template are lost when that template is reloaded.
+type Loader struct {
+	env        *Environment
+	fsys       fs.FS
+	extensions []string
+
+	// serializes reloads
+	mutex sync.Mutex
+
+	// last successfully loaded templates
+	set atomic.Pointer[templateSet]
+}
+
+// templateSet is a set of templates loaded at once
+type templateSet struct {
+	// loaded files, by file path
+	files map[string]loadedFile
+
+	// templates with partials registered, by name
+	templates map[string]*Template
+}
+
+// loadedFile is a parsed template file
+type loadedFile struct {
+	name    string
+	modTime time.Time
+	size    int64
+
+	// parsed template, without partials
+	tpl *Template
+}
+
+// NewLoader instanciates a loader for the files of fsys with given extensions, or with the .hbs or .handlebars
+// extension if none are given, and loads all templates.
+//
+// Use os.DirFS() to load templates from a directory.
+func NewLoader(fsys fs.FS, extensions ...string) (*Loader, error) {
+	return defaultEnv.NewLoader(fsys, extensions...)
+}
+
+// Environment returns the environment that templates are loaded with, where their helpers, partials and decorators
+// must be registered.
+func (l *Loader) Environment() *Environment {
+	return l.env
+}
+
+// Template returns the template with given name.
+//
+// Each reload returns new templates, so don't register helpers, partials or decorators on the returned template, but
+// on the loader environment instead.
+func (l *Loader) Template(name string) (*Template, error) {
+	if tpl := l.set.Load().templates[name]; tpl != nil {
+		return tpl, nil
+	}
+
+	return nil, fmt.Errorf("Template not found: %s", name)
+}
+
+// Reload parses again the template files that were modified, added or removed since last load.
+//
+// Files are considered modified when their modification time or size changed. On error, the templates loaded
+// previously are kept.
+func (l *Loader) Reload() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	prev := l.set.Load()
+	if prev == nil {
+		prev = &templateSet{}
+	}
+
+	files := make(map[string]loadedFile)
+	names := make(map[string]string)
+	changed := false
+
+	err := fs.WalkDir(l.fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !hasExtension(filePath, l.extensions) {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		name := filePath[:len(filePath)-len(path.Ext(filePath))]
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("several files for template %s: %s and %s", name, other, filePath)
+		}
+
+		names[name] = filePath
+
+		file, ok := prev.files[filePath]
+		if !ok || !file.modTime.Equal(info.ModTime()) || (file.size != info.Size()) {
+			b, err := fs.ReadFile(l.fsys, filePath)
+			if err != nil {
+				return err
+			}
+
+			tpl, err := l.env.parse(filePath, string(b))
+			if err != nil {
+				return fmt.Errorf("%s: %w", filePath, err)
+			}
+
+			file = loadedFile{
+				name:    name,
+				modTime: info.ModTime(),
+				size:    info.Size(),
+				tpl:     tpl,
+			}
+
+			changed = true
+		}
+
+		files[filePath] = file
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !changed && (len(files) == len(prev.files)) && (l.set.Load() != nil) {
+		return nil
+	}
+
+	set := &templateSet{
+		files:     files,
+		templates: make(map[string]*Template, len(files)),
+	}
+
+	for _, file := range files {
+		tpl := file.tpl.Clone()
+
+		for _, partial := range files {
+			tpl.RegisterPartialTemplate(partial.name, partial.tpl)
+		}
+
+		set.templates[file.name] = tpl
+	}
+
+	l.set.Store(set)
+
+	return nil
+}
+
+// Watch polls the template files at given interval and reloads modified ones, until ctx is done.
+//
+// Reload errors are reported to onError, if not nil, and the last templates loaded successfully are kept.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); (err != nil) && (onError != nil) {
+				onError(err)
+			}
+		}
+	}
+}
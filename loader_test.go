@@ -0,0 +1,205 @@
+package raymond
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func loaderExec(t *testing.T, l *Loader, name string, ctx any) string {
+	t.Helper()
+
+	tpl, err := l.Template(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tpl.MustExec(ctx)
+}
+
+func TestLoader(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"page.hbs":           {Data: []byte(`{{> emails/header}}-{{body}}`), ModTime: time.Unix(1, 0)},
+		"emails/header.hbs":  {Data: []byte(`<h1>{{title}}</h1>`), ModTime: time.Unix(1, 0)},
+		"emails/welcome.txt": {Data: []byte(`not a template`), ModTime: time.Unix(1, 0)},
+	}
+
+	ctx := map[string]string{"title": "Hi", "body": "there"}
+
+	l, err := NewLoader(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output := loaderExec(t, l, "page", ctx); output != "<h1>Hi</h1>-there" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if _, err := l.Template("emails/welcome"); err == nil {
+		t.Errorf("Error expected for a file without template extension")
+	}
+
+	old, _ := l.Template("page")
+
+	// unchanged files
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tpl, _ := l.Template("page"); tpl != old {
+		t.Errorf("Templates reloaded without any change")
+	}
+
+	// modified partial
+	fsys["emails/header.hbs"] = &fstest.MapFile{Data: []byte(`<h2>{{title}}</h2>`), ModTime: time.Unix(2, 0)}
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := loaderExec(t, l, "page", ctx); output != "<h2>Hi</h2>-there" {
+		t.Errorf("Unexpected output after reload: %q", output)
+	}
+
+	if output := old.MustExec(ctx); output != "<h1>Hi</h1>-there" {
+		t.Errorf("Previously loaded template modified: %q", output)
+	}
+
+	// parse error
+	fsys["page.hbs"] = &fstest.MapFile{Data: []byte(`{{#if}}`), ModTime: time.Unix(3, 0)}
+
+	if err := l.Reload(); (err == nil) || !strings.HasPrefix(err.Error(), "page.hbs: ") {
+		t.Errorf("Unexpected reload error: %v", err)
+	}
+
+	if output := loaderExec(t, l, "page", ctx); output != "<h2>Hi</h2>-there" {
+		t.Errorf("Unexpected output after reload error: %q", output)
+	}
+
+	// added and removed files
+	fsys["page.hbs"] = &fstest.MapFile{Data: []byte(`{{> footer}}`), ModTime: time.Unix(4, 0)}
+	fsys["footer.handlebars"] = &fstest.MapFile{Data: []byte(`bye`), ModTime: time.Unix(4, 0)}
+	delete(fsys, "emails/header.hbs")
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := loaderExec(t, l, "page", ctx); output != "bye" {
+		t.Errorf("Unexpected output after files added: %q", output)
+	}
+
+	if _, err := l.Template("emails/header"); err == nil {
+		t.Errorf("Error expected for a removed template")
+	}
+}
+
+func TestLoaderEnvironment(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"page.hbs": {Data: []byte(`{{upper title}}{{> footer}}`), ModTime: time.Unix(1, 0)},
+	}
+
+	env := NewEnvironment()
+
+	l, err := env.NewLoader(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Environment() != env {
+		t.Errorf("Unexpected loader environment")
+	}
+
+	// registered after templates were loaded
+	env.RegisterHelper("upper", strings.ToUpper)
+	env.RegisterPartial("footer", `-{{title}}`)
+
+	ctx := map[string]string{"title": "hi"}
+
+	if output := loaderExec(t, l, "page", ctx); output != "HI-hi" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// modified template
+	fsys["page.hbs"] = &fstest.MapFile{Data: []byte(`{{upper title}}!{{> footer}}`), ModTime: time.Unix(2, 0)}
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := loaderExec(t, l, "page", ctx); output != "HI!-hi" {
+		t.Errorf("Unexpected output after reload: %q", output)
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewLoader(fstest.MapFS{"page.hbs": {Data: []byte(`{{/if}}`)}}); err == nil {
+		t.Errorf("Error expected for an invalid template")
+	}
+
+	fsys := fstest.MapFS{
+		"page.hbs":        {Data: []byte(`hbs`)},
+		"page.handlebars": {Data: []byte(`handlebars`)},
+	}
+
+	if _, err := NewLoader(fsys); (err == nil) || !strings.Contains(err.Error(), "several files for template page") {
+		t.Errorf("Unexpected error for duplicate templates: %v", err)
+	}
+
+	l, err := NewLoader(fsys, ".handlebars")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output := loaderExec(t, l, "page", nil); output != "handlebars" {
+		t.Errorf("Unexpected output with custom extension: %q", output)
+	}
+}
+
+func TestLoaderWatch(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"page.hbs": {Data: []byte(`ok`)},
+	}
+
+	l, err := NewLoader(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["page.hbs"] = &fstest.MapFile{Data: []byte(`{{#if}}`), ModTime: time.Unix(1, 0)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+
+	go l.Watch(ctx, time.Millisecond, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+
+		cancel()
+	})
+
+	select {
+	case err := <-errs:
+		if !strings.HasPrefix(err.Error(), "page.hbs: ") {
+			t.Errorf("Unexpected watch error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Watch did not report reload error")
+	}
+
+	if output := loaderExec(t, l, "page", nil); output != "ok" {
+		t.Errorf("Unexpected output after watch error: %q", output)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 
 	"github.com/yoinkai/raymond/v2/ast"
@@ -28,6 +29,18 @@ type parser struct {
 
 	// All tokens have been retreieved from lexer
 	lexOver bool
+
+	// Byte position following the last consumed token
+	end int
+
+	// Record syntax errors and resume parsing at next statement, see ParseAll()
+	recovering bool
+
+	// Syntax errors recorded in recovering mode
+	errors []*Error
+
+	// Position of the lexer error, -1 if none
+	lexErrPos int
 }
 
 var (
@@ -39,7 +52,8 @@ var (
 // new instanciates a new parser
 func new(input string) *parser {
 	return &parser{
-		lex: lexer.Scan(input),
+		lex:       lexer.Scan(input),
+		lexErrPos: -1,
 	}
 }
 
@@ -67,6 +81,38 @@ func Parse(input string) (result *ast.Program, err error) {
 	return
 }
 
+// ParseAll analyzes given input and returns the AST root node, with all syntax errors found, sorted by position.
+//
+// Contrary to Parse(), parsing resumes at next statement after a syntax error, so the returned AST contains all
+// statements that could be parsed: an erroneous statement is skipped, a block closed by another name is kept, and an
+// unclosed block ends with input. Nothing is parsed after a lexer error.
+func ParseAll(input string) (*ast.Program, []*Error) {
+	parser := new(input)
+	parser.recovering = true
+
+	// parse
+	result := parser.parseProgram()
+
+	// skip unexpected tokens that end the program before EOF, and resume parsing
+	for !parser.isToken(lexer.TokenEOF) {
+		parser.addError(tokenError(parser.shift(), "Syntax error"))
+		parser.skipStatement()
+
+		result.Body = append(result.Body, parser.parseProgram().Body...)
+	}
+
+	parser.setEnd(&result.Loc)
+
+	// fix whitespaces
+	processWhitespaces(result)
+
+	sort.SliceStable(parser.errors, func(i, j int) bool {
+		return parser.errors[i].Pos < parser.errors[j].Pos
+	})
+
+	return result, parser.errors
+}
+
 // errRecover recovers parsing panic
 func errRecover(errp *error) {
 	e := recover()
@@ -82,38 +128,142 @@ func errRecover(errp *error) {
 	}
 }
 
-// errPanic panics
-func errPanic(err error, line int) {
-	panic(fmt.Errorf("Parse error on line %d:\n%s", line, err))
+// Error is a syntax error returned by Parse() and ParseAll().
+type Error struct {
+	// error message, without position
+	Message string
+
+	// byte position in input string
+	Pos int
+
+	// line number in input string
+	Line int
+}
+
+// Error returns the error message, prefixed with the line number
+func (err *Error) Error() string {
+	return fmt.Sprintf("Parse error on line %d:\n%s", err.Line, err.Message)
+}
+
+// errPanic panics with a syntax error at given position
+func errPanic(msg string, pos int, line int) {
+	panic(&Error{Message: msg, Pos: pos, Line: line})
+}
+
+// nodeError instanciates a syntax error with given node infos
+func nodeError(node ast.Node, msg string) *Error {
+	loc := node.Location()
+	return &Error{Message: fmt.Sprintf("%s\nNode: %s", msg, node), Pos: loc.Pos, Line: loc.Line}
+}
+
+// tokenError instanciates a syntax error with given Token infos
+func tokenError(tok *lexer.Token, msg string) *Error {
+	return &Error{Message: fmt.Sprintf("%s\nToken: %s", msg, tok), Pos: tok.Pos, Line: tok.Line}
 }
 
 // errNode panics with given node infos
 func errNode(node ast.Node, msg string) {
-	errPanic(fmt.Errorf("%s\nNode: %s", msg, node), node.Location().Line)
+	panic(nodeError(node, msg))
 }
 
 // errNode panics with given Token infos
 func errToken(tok *lexer.Token, msg string) {
-	errPanic(fmt.Errorf("%s\nToken: %s", msg, tok), tok.Line)
+	panic(tokenError(tok, msg))
 }
 
 // errNode panics because of an unexpected Token kind
 func errExpected(expect lexer.TokenKind, tok *lexer.Token) {
-	errPanic(fmt.Errorf("Expecting %s, got: '%s'", expect, tok), tok.Line)
+	errPanic(fmt.Sprintf("Expecting %s, got: '%s'", expect, tok), tok.Pos, tok.Line)
 }
 
 // program : statement*
 func (p *parser) parseProgram() *ast.Program {
-	result := ast.NewProgram(p.next().Pos, p.next().Line)
+	tok := p.next()
+
+	result := ast.NewProgram(tok.Pos, tok.Line)
+	result.Column = tok.Column
 
 	for p.isStatement() {
-		result.AddStatement(p.parseStatement())
+		if p.recovering {
+			p.parseStatementOrSkip(result)
+		} else {
+			result.AddStatement(p.parseStatement())
+		}
 	}
 
+	p.setEnd(&result.Loc)
+
 	return result
 }
 
-// statement : mustache | block | rawBlock | partial | content | COMMENT
+// parseStatementOrSkip adds next statement to given program, or skips it on syntax error
+func (p *parser) parseStatementOrSkip(program *ast.Program) {
+	defer p.skipOnError()
+
+	program.AddStatement(p.parseStatement())
+}
+
+// skipOnError recovers a syntax error panic, records it and skips tokens up to next statement
+func (p *parser) skipOnError() {
+	if e := recover(); e != nil {
+		err, ok := e.(*Error)
+		if !ok {
+			panic(e)
+		}
+
+		p.addError(err)
+		p.skipStatement()
+	}
+}
+
+// skipStatement consumes tokens up to the start of next statement, or up to a token that ends a program
+func (p *parser) skipStatement() {
+	for !p.isStatement() {
+		switch p.next().Kind {
+		case lexer.TokenEOF, lexer.TokenOpenEndBlock, lexer.TokenInverse, lexer.TokenOpenInverseChain:
+			return
+		}
+
+		p.shift()
+	}
+}
+
+// addError records given syntax error, unless it is caused by a lexer error or already recorded
+func (p *parser) addError(err *Error) {
+	if (p.lexErrPos >= 0) && (err.Pos >= p.lexErrPos) {
+		// parsing stopped because of a lexer error, that is already recorded
+		return
+	}
+
+	for _, e := range p.errors {
+		if (e.Pos == err.Pos) && (e.Message == err.Message) {
+			// already reported
+			return
+		}
+	}
+
+	p.errors = append(p.errors, err)
+}
+
+// softError panics with given syntax error, or records it in recovering mode so that parsing goes on
+func (p *parser) softError(err *Error) {
+	if !p.recovering {
+		panic(err)
+	}
+
+	p.addError(err)
+}
+
+// setEnd sets the end of given location to the end of the last consumed token
+func (p *parser) setEnd(loc *ast.Loc) {
+	loc.End = p.end
+	if loc.End < loc.Pos {
+		// empty program
+		loc.End = loc.Pos
+	}
+}
+
+// statement : mustache | block | decorator | decoratorBlock | rawBlock | partial | partialBlock | content | COMMENT
 func (p *parser) parseStatement() ast.Node {
 	var result ast.Node
 
@@ -126,15 +276,24 @@ func (p *parser) parseStatement() ast.Node {
 	case lexer.TokenOpenBlock:
 		// block
 		result = p.parseBlock()
+	case lexer.TokenOpenDecoratorBlock:
+		// decoratorBlock
+		result = p.parseDecoratorBlock()
 	case lexer.TokenOpenInverse:
 		// block
 		result = p.parseInverse()
 	case lexer.TokenOpenRawBlock:
 		// rawBlock
 		result = p.parseRawBlock()
+	case lexer.TokenOpenDecorator:
+		// decorator
+		result = p.parseDecorator()
 	case lexer.TokenOpenPartial:
 		// partial
 		result = p.parsePartial()
+	case lexer.TokenOpenPartialBlock:
+		// partialBlock
+		result = p.parsePartialBlock()
 	case lexer.TokenContent:
 		// content
 		result = p.parseContent()
@@ -153,8 +312,8 @@ func (p *parser) isStatement() bool {
 	}
 
 	switch p.next().Kind {
-	case lexer.TokenOpen, lexer.TokenOpenUnescaped, lexer.TokenOpenBlock,
-		lexer.TokenOpenInverse, lexer.TokenOpenRawBlock, lexer.TokenOpenPartial,
+	case lexer.TokenOpen, lexer.TokenOpenUnescaped, lexer.TokenOpenBlock, lexer.TokenOpenDecorator, lexer.TokenOpenDecoratorBlock,
+		lexer.TokenOpenInverse, lexer.TokenOpenRawBlock, lexer.TokenOpenPartial, lexer.TokenOpenPartialBlock,
 		lexer.TokenContent, lexer.TokenComment:
 		return true
 	}
@@ -171,7 +330,10 @@ func (p *parser) parseContent() *ast.ContentStatement {
 		errExpected(lexer.TokenContent, tok)
 	}
 
-	return ast.NewContentStatement(tok.Pos, tok.Line, tok.Val)
+	result := ast.NewContentStatement(tok.Pos, tok.Line, tok.Val)
+	result.Column, result.End = tok.Column, tok.End
+
+	return result
 }
 
 // COMMENT
@@ -183,6 +345,7 @@ func (p *parser) parseComment() *ast.CommentStatement {
 	value = rCloseComment.ReplaceAllString(value, "")
 
 	result := ast.NewCommentStatement(tok.Pos, tok.Line, value)
+	result.Column, result.End = tok.Column, tok.End
 	result.Strip = ast.NewStripForStr(tok.Val)
 
 	return result
@@ -207,8 +370,11 @@ func (p *parser) parseExpressionParamsHash() ([]ast.Node, *ast.Hash) {
 }
 
 // helperName param* hash?
+//
+// The expression starts at given opening token, its end is set by the caller when consuming the closing token.
 func (p *parser) parseExpression(tok *lexer.Token) *ast.Expression {
 	result := ast.NewExpression(tok.Pos, tok.Line)
+	result.Column = tok.Column
 
 	// helperName
 	result.Path = p.parseHelperName()
@@ -227,6 +393,8 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 	tok := p.shift()
 
 	result := ast.NewBlockStatement(tok.Pos, tok.Line)
+	result.Column = tok.Column
+	result.Raw = true
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -239,11 +407,14 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 		errExpected(lexer.TokenCloseRawBlock, tok)
 	}
 
+	result.Expression.End = p.end
+
 	// content
 	// @todo Is content mandatory in a raw block ?
 	content := p.parseContent()
 
-	program := ast.NewProgram(tok.Pos, tok.Line)
+	program := ast.NewProgram(content.Pos, content.Line)
+	program.Column, program.End = content.Column, content.End
 	program.AddStatement(content)
 
 	result.Program = program
@@ -273,6 +444,8 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 		errExpected(lexer.TokenCloseRawBlock, tok)
 	}
 
+	result.End = p.end
+
 	return result
 }
 
@@ -292,10 +465,57 @@ func (p *parser) parseBlock() *ast.BlockStatement {
 	}
 
 	// closeBlock
-	p.parseCloseBlock(result)
+	result.CloseStrip = p.parseCloseBlock(result.Expression.Path)
 
 	setBlockInverseStrip(result)
 
+	result.End = p.end
+
+	return result
+}
+
+// decoratorBlock : openDecoratorBlock program closeBlock
+// openDecoratorBlock : OPEN_DECORATOR_BLOCK helperName param* hash? blockParams? CLOSE
+func (p *parser) parseDecoratorBlock() *ast.DecoratorBlock {
+	// OPEN_DECORATOR_BLOCK
+	tok := p.shift()
+
+	result := ast.NewDecoratorBlock(tok.Pos, tok.Line)
+	result.Column = tok.Column
+
+	// helperName param* hash?
+	result.Expression = p.parseExpression(tok)
+
+	// blockParams?
+	var blockParams []string
+	if p.isBlockParams() {
+		blockParams = p.parseBlockParams()
+	}
+
+	// CLOSE
+	tokClose := p.shift()
+	if tokClose.Kind != lexer.TokenClose {
+		errExpected(lexer.TokenClose, tokClose)
+	}
+
+	result.OpenStrip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.Expression.End = p.end
+
+	// program
+	result.Program = p.parseProgram()
+	result.Program.BlockParams = blockParams
+
+	if p.isInverseChain() {
+		p.softError(tokenError(p.next(), "Unexpected inverse in decorator block"))
+
+		// ignore inverse
+		p.parseInverseChain()
+	}
+
+	// closeBlock
+	result.CloseStrip = p.parseCloseBlock(result.Expression.Path)
+	result.End = p.end
+
 	return result
 }
 
@@ -336,10 +556,12 @@ func (p *parser) parseInverse() *ast.BlockStatement {
 	}
 
 	// closeBlock
-	p.parseCloseBlock(result)
+	result.CloseStrip = p.parseCloseBlock(result.Expression.Path)
 
 	setBlockInverseStrip(result)
 
+	result.End = p.end
+
 	return result
 }
 
@@ -348,6 +570,7 @@ func (p *parser) parseOpenBlockExpression(tok *lexer.Token) (*ast.BlockStatement
 	var blockParams []string
 
 	result := ast.NewBlockStatement(tok.Pos, tok.Line)
+	result.Column = tok.Column
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -370,7 +593,10 @@ func (p *parser) parseInverseChain() *ast.Program {
 		return p.parseInverseAndProgram()
 	}
 
-	result := ast.NewProgram(p.next().Pos, p.next().Line)
+	tok := p.next()
+
+	result := ast.NewProgram(tok.Pos, tok.Line)
+	result.Column = tok.Column
 
 	// openInverseChain
 	block, blockParams := p.parseOpenBlock()
@@ -388,9 +614,13 @@ func (p *parser) parseInverseChain() *ast.Program {
 
 	setBlockInverseStrip(block)
 
+	p.setEnd(&block.Loc)
+
 	result.Chained = true
 	result.AddStatement(block)
 
+	p.setEnd(&result.Loc)
+
 	return result
 }
 
@@ -428,13 +658,37 @@ func (p *parser) parseOpenBlock() (*ast.BlockStatement, []string) {
 	}
 
 	result.OpenStrip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.Expression.End = p.end
 
 	// named returned values
 	return result, blockParams
 }
 
 // closeBlock : OPEN_ENDBLOCK helperName CLOSE
-func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
+//
+// The helperName must match given open block name, unless that is a subexpression.
+//
+// In recovering mode, a block is closed by EOF, and a syntax error in the closeBlock is skipped.
+func (p *parser) parseCloseBlock(openName ast.Node) *ast.Strip {
+	if p.recovering {
+		for p.isInverseChain() {
+			p.addError(tokenError(p.next(), "Unexpected inverse"))
+
+			// ignore inverse
+			p.parseInverseChain()
+		}
+
+		if p.isToken(lexer.TokenEOF) {
+			if p.lexErrPos < 0 {
+				p.addError(nodeError(openName, "Unclosed block"))
+			}
+
+			return nil
+		}
+
+		defer p.skipOnError()
+	}
+
 	// OPEN_ENDBLOCK
 	tok := p.shift()
 	if tok.Kind != lexer.TokenOpenEndBlock {
@@ -446,12 +700,9 @@ func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
 
 	closeName, ok := ast.HelperNameStr(endID)
 	if !ok {
-		errNode(endID, "Erroneous closing expression")
-	}
-
-	openName := block.Expression.Canonical()
-	if openName != closeName {
-		errNode(endID, fmt.Sprintf("%s doesn't match %s", openName, closeName))
+		p.softError(nodeError(endID, "Erroneous closing expression"))
+	} else if openStr, ok := ast.HelperNameStr(openName); ok && (openStr != closeName) {
+		p.softError(nodeError(endID, fmt.Sprintf("%s doesn't match %s", openStr, closeName)))
 	}
 
 	// CLOSE
@@ -460,7 +711,7 @@ func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
 		errExpected(lexer.TokenClose, tokClose)
 	}
 
-	block.CloseStrip = ast.NewStrip(tok.Val, tokClose.Val)
+	return ast.NewStrip(tok.Val, tokClose.Val)
 }
 
 // mustache : OPEN helperName param* hash? CLOSE
@@ -481,6 +732,7 @@ func (p *parser) parseMustache() *ast.MustacheStatement {
 	}
 
 	result := ast.NewMustacheStatement(tok.Pos, tok.Line, unescaped)
+	result.Column = tok.Column
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -492,6 +744,32 @@ func (p *parser) parseMustache() *ast.MustacheStatement {
 	}
 
 	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.End = p.end
+	result.Expression.End = p.end
+
+	return result
+}
+
+// decorator : OPEN_DECORATOR helperName param* hash? CLOSE
+func (p *parser) parseDecorator() *ast.Decorator {
+	// OPEN_DECORATOR
+	tok := p.shift()
+
+	result := ast.NewDecorator(tok.Pos, tok.Line)
+	result.Column = tok.Column
+
+	// helperName param* hash?
+	result.Expression = p.parseExpression(tok)
+
+	// CLOSE
+	tokClose := p.shift()
+	if tokClose.Kind != lexer.TokenClose {
+		errExpected(lexer.TokenClose, tokClose)
+	}
+
+	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.End = p.end
+	result.Expression.End = p.end
 
 	return result
 }
@@ -502,12 +780,14 @@ func (p *parser) parsePartial() *ast.PartialStatement {
 	tok := p.shift()
 
 	result := ast.NewPartialStatement(tok.Pos, tok.Line)
+	result.Column = tok.Column
 
 	// partialName
 	result.Name = p.parsePartialName()
 
 	// param* hash?
 	result.Params, result.Hash = p.parseExpressionParamsHash()
+	p.checkPartialHash(result.Hash)
 
 	// CLOSE
 	tokClose := p.shift()
@@ -516,6 +796,65 @@ func (p *parser) parsePartial() *ast.PartialStatement {
 	}
 
 	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.End = p.end
+
+	return result
+}
+
+// checkPartialHash reports an error if given partial hash has duplicate keys
+func (p *parser) checkPartialHash(hash *ast.Hash) {
+	if hash == nil {
+		return
+	}
+
+	keys := make(map[string]bool, len(hash.Pairs))
+
+	for _, pair := range hash.Pairs {
+		if keys[pair.Key] {
+			p.softError(nodeError(pair, fmt.Sprintf("Duplicate partial parameter: %s", pair.Key)))
+		}
+
+		keys[pair.Key] = true
+	}
+}
+
+// partialBlock : openPartialBlock program closeBlock
+// openPartialBlock : OPEN_PARTIAL_BLOCK partialName param* hash? CLOSE
+func (p *parser) parsePartialBlock() *ast.PartialStatement {
+	// OPEN_PARTIAL_BLOCK
+	tok := p.shift()
+
+	result := ast.NewPartialStatement(tok.Pos, tok.Line)
+	result.Column = tok.Column
+
+	// partialName
+	result.Name = p.parsePartialName()
+
+	// param* hash?
+	result.Params, result.Hash = p.parseExpressionParamsHash()
+	p.checkPartialHash(result.Hash)
+
+	// CLOSE
+	tokClose := p.shift()
+	if tokClose.Kind != lexer.TokenClose {
+		errExpected(lexer.TokenClose, tokClose)
+	}
+
+	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+
+	// program
+	result.Program = p.parseProgram()
+
+	if p.isInverseChain() {
+		p.softError(tokenError(p.next(), "Unexpected inverse in partial block"))
+
+		// ignore inverse
+		p.parseInverseChain()
+	}
+
+	// closeBlock
+	result.CloseStrip = p.parseCloseBlock(result.Name)
+	result.End = p.end
 
 	return result
 }
@@ -558,6 +897,7 @@ func (p *parser) parseSexpr() *ast.SubExpression {
 	tok := p.shift()
 
 	result := ast.NewSubExpression(tok.Pos, tok.Line)
+	result.Column = tok.Column
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -568,6 +908,9 @@ func (p *parser) parseSexpr() *ast.SubExpression {
 		errExpected(lexer.TokenCloseSexpr, tok)
 	}
 
+	result.End = p.end
+	result.Expression.End = p.end
+
 	return result
 }
 
@@ -582,6 +925,7 @@ func (p *parser) parseHash() *ast.Hash {
 	firstLoc := pairs[0].Location()
 
 	result := ast.NewHash(firstLoc.Pos, firstLoc.Line)
+	result.Column, result.End = firstLoc.Column, p.end
 	result.Pairs = pairs
 
 	return result
@@ -604,6 +948,7 @@ func (p *parser) parseHashSegment() *ast.HashPair {
 	param := p.parseParam()
 
 	result := ast.NewHashPair(tok.Pos, tok.Line)
+	result.Column, result.End = tok.Column, p.end
 	result.Key = tok.Val
 	result.Val = param
 
@@ -645,17 +990,26 @@ func (p *parser) parseHelperName() ast.Node {
 	case lexer.TokenBoolean:
 		// BOOLEAN
 		p.shift()
-		result = ast.NewBooleanLiteral(tok.Pos, tok.Line, (tok.Val == "true"), tok.Val)
+
+		lit := ast.NewBooleanLiteral(tok.Pos, tok.Line, (tok.Val == "true"), tok.Val)
+		lit.Column, lit.End = tok.Column, tok.End
+		result = lit
 	case lexer.TokenNumber:
 		// NUMBER
 		p.shift()
 
 		val, isInt := parseNumber(tok)
-		result = ast.NewNumberLiteral(tok.Pos, tok.Line, val, isInt, tok.Val)
+
+		lit := ast.NewNumberLiteral(tok.Pos, tok.Line, val, isInt, tok.Val)
+		lit.Column, lit.End = tok.Column, tok.End
+		result = lit
 	case lexer.TokenString:
 		// STRING
 		p.shift()
-		result = ast.NewStringLiteral(tok.Pos, tok.Line, tok.Val)
+
+		lit := ast.NewStringLiteral(tok.Pos, tok.Line, tok.Val)
+		lit.Column, lit.End = tok.Column, tok.End
+		result = lit
 	case lexer.TokenData:
 		// dataName
 		result = p.parseDataName()
@@ -708,10 +1062,15 @@ func (p *parser) parsePartialName() ast.Node {
 // dataName : DATA pathSegments
 func (p *parser) parseDataName() *ast.PathExpression {
 	// DATA
-	p.shift()
+	tok := p.shift()
 
 	// pathSegments
-	return p.parsePath(true)
+	result := p.parsePath(true)
+
+	// path starts at the data token
+	result.Pos, result.Line, result.Column = tok.Pos, tok.Line, tok.Column
+
+	return result
 }
 
 // path : pathSegments
@@ -728,6 +1087,7 @@ func (p *parser) parsePath(data bool) *ast.PathExpression {
 	}
 
 	result := ast.NewPathExpression(tok.Pos, tok.Line, data)
+	result.Column = tok.Column
 	result.Part(tok.Val)
 
 	for p.isPathSep() {
@@ -751,6 +1111,8 @@ func (p *parser) parsePath(data bool) *ast.PathExpression {
 		}
 	}
 
+	result.End = p.end
+
 	return result
 }
 
@@ -767,6 +1129,14 @@ func (p *parser) ensure(index int) {
 		// fetch next token
 		tok := p.lex.NextToken()
 
+		if (tok.Kind == lexer.TokenError) && p.recovering {
+			// record lexer error, and parse as if input ends here
+			p.errors = append(p.errors, tokenError(&tok, "Lexer error"))
+			p.lexErrPos = tok.Pos
+
+			tok = lexer.Token{Kind: lexer.TokenEOF, Pos: tok.Pos, End: tok.Pos, Line: tok.Line, Column: tok.Column}
+		}
+
 		// queue it
 		p.tokens = append(p.tokens, &tok)
 
@@ -806,11 +1176,18 @@ func (p *parser) shift() *lexer.Token {
 
 	result, p.tokens = p.tokens[0], p.tokens[1:]
 
+	if (result.Kind == lexer.TokenEOF) && p.recovering {
+		// keep EOF, so that parsing can resume after an unexpected end of input
+		p.tokens = append(p.tokens, result)
+	}
+
 	// check error token
 	if result.Kind == lexer.TokenError {
 		errToken(result, "Lexer error")
 	}
 
+	p.end = result.End
+
 	return result
 }
 
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/yoinkai/raymond/v2/ast"
@@ -82,6 +84,17 @@ var parserTests = []parserTest{
 	{"parses block with block params", `{{#foo as |bar baz|}}content{{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n    BLOCK PARAMS: [ bar baz ]\n    CONTENT[ 'content' ]\n"},
 	{"parses inverse block with block params", `{{^foo as |bar baz|}}content{{/foo}}`, "BLOCK:\n  PATH:foo []\n  {{^}}\n    BLOCK PARAMS: [ bar baz ]\n    CONTENT[ 'content' ]\n"},
 	{"parses chained inverse block with block params", `{{#foo}}{{else foo as |bar baz|}}content{{/foo}}`, "BLOCK:\n  PATH:foo []\n  PROGRAM:\n  {{^}}\n    BLOCK:\n      PATH:foo []\n      PROGRAM:\n        BLOCK PARAMS: [ bar baz ]\n        CONTENT[ 'content' ]\n"},
+
+	{"parses inline partials", `{{#*inline "foo"}}bar{{/inline}}`, "DECORATOR BLOCK:\n  PATH:inline [\"foo\"]\n  PROGRAM:\n    CONTENT[ 'bar' ]\n"},
+	{"removes standalone inline partials whitespace", "{{#*inline \"foo\"}}\n  bar\n{{/inline}}\nbaz", "DECORATOR BLOCK:\n  PATH:inline [\"foo\"]\n  PROGRAM:\n    CONTENT[ '  bar\n' ]\nCONTENT[ 'baz' ]\n"},
+
+	{"parses decorators", `{{* foo bar baz=1}}`, "{{* DECORATOR:PATH:foo [PATH:bar] HASH{baz=NUMBER{1}} }}\n"},
+	{"parses decorator blocks", `{{#* foo bar}}baz{{/foo}}`, "DECORATOR BLOCK:\n  PATH:foo [PATH:bar]\n  PROGRAM:\n    CONTENT[ 'baz' ]\n"},
+
+	{"parses partial blocks", `{{#> foo}}bar{{/foo}}`, "{{#> PARTIAL:foo }}\n  PROGRAM:\n    CONTENT[ 'bar' ]\n"},
+	{"parses partial blocks with arguments", `{{#> foo context hash=value}}bar{{/foo}}`, "{{#> PARTIAL:foo PATH:context HASH{hash=PATH:value} }}\n  PROGRAM:\n    CONTENT[ 'bar' ]\n"},
+	{"parses dynamic partial blocks", `{{#> (name)}}bar{{/foo}}`, "{{#> PARTIAL:name [] }}\n  PROGRAM:\n    CONTENT[ 'bar' ]\n"},
+	{"removes standalone partial blocks whitespace", "{{#> foo}}\n  bar\n{{/foo}}\nbaz", "{{#> PARTIAL:foo }}\n  PROGRAM:\n    CONTENT[ '  bar\n' ]\nCONTENT[ 'baz' ]\n"},
 }
 
 func TestParser(t *testing.T) {
@@ -132,6 +145,13 @@ var parserErrorTests = []parserTest{
 	{"block param must have at least one param", `{{#foo as ||}}content{{/foo}}`, "Expecting ID"},
 	{"open block params must be closed", `{{#foo as |}}content{{/foo}}`, "Expecting ID"},
 
+	{"decorator block names must match", `{{#*inline "foo"}}{{/bar}}`, "inline doesn't match bar"},
+	{"decorator block must not have an inverse", `{{#*inline "foo"}}{{else}}{{/inline}}`, "Unexpected inverse in decorator block"},
+	{"partial block names must match", `{{#> foo}}{{/bar}}`, "foo doesn't match bar"},
+	{"partial block must not have an inverse", `{{#> foo}}{{else}}{{/foo}}`, "Unexpected inverse in partial block"},
+	{"partial parameters must be unique", `{{> foo bar=1 bar=2}}`, "Duplicate partial parameter: bar"},
+	{"partial block parameters must be unique", `{{#> foo bar=1 baz=2 bar=3}}{{/foo}}`, "Duplicate partial parameter: bar"},
+
 	{"a path must start with an ID", `{{#/}}content{{/foo}}`, "Expecting ID"},
 	{"a path must end with an ID", `{{foo/bar/}}`, "Expecting ID"},
 
@@ -180,6 +200,25 @@ func TestParserErrors(t *testing.T) {
 	}
 }
 
+func TestParserErrorPosition(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("hello\n{{#foo}}{{/bar}}")
+
+	var perr *Error
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a parser Error, got: %v", err)
+	}
+
+	if (perr.Pos != 17) || (perr.Line != 2) {
+		t.Errorf("Unexpected error position: %d on line %d", perr.Pos, perr.Line)
+	}
+
+	if expected := "Parse error on line 2:\n" + perr.Message; err.Error() != expected {
+		t.Errorf("Unexpected error message\nexpected\n\t%q\ngot\n\t%q", expected, err.Error())
+	}
+}
+
 // package example
 func Example() {
 	source := "You know {{nothing}} John Snow"
@@ -198,3 +237,176 @@ func Example() {
 	// {{ PATH:nothing [] }}
 	// CONTENT[ ' John Snow' ]
 }
+
+func TestParserLocations(t *testing.T) {
+	t.Parallel()
+
+	program, err := Parse("<é>{{#if @root.ok}}\n  {{foo \"bar\" (baz 1) a=true}}{{else}}{{! hi }}{{/if}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := program.Body[1].(*ast.BlockStatement)
+	mustache := block.Program.Body[1].(*ast.MustacheStatement)
+
+	tests := []struct {
+		name     string
+		loc      ast.Loc
+		expected ast.Loc
+	}{
+		{"program", program.Loc, ast.Loc{Pos: 0, Line: 1, Column: 1, End: 75}},
+		{"content", program.Body[0].Location(), ast.Loc{Pos: 0, Line: 1, Column: 1, End: 4}},
+		{"block", block.Loc, ast.Loc{Pos: 4, Line: 1, Column: 4, End: 75}},
+		{"block expression", block.Expression.Loc, ast.Loc{Pos: 4, Line: 1, Column: 4, End: 20}},
+		{"data path", block.Expression.Params[0].Location(), ast.Loc{Pos: 10, Line: 1, Column: 10, End: 18}},
+		{"block program", block.Program.Loc, ast.Loc{Pos: 20, Line: 1, Column: 20, End: 51}},
+		{"mustache", mustache.Loc, ast.Loc{Pos: 23, Line: 2, Column: 3, End: 51}},
+		{"string", mustache.Expression.Params[0].Location(), ast.Loc{Pos: 29, Line: 2, Column: 9, End: 34}},
+		{"subexpression", mustache.Expression.Params[1].Location(), ast.Loc{Pos: 35, Line: 2, Column: 15, End: 42}},
+		{"hash", mustache.Expression.Hash.Loc, ast.Loc{Pos: 43, Line: 2, Column: 23, End: 49}},
+		{"inverse", block.Inverse.Loc, ast.Loc{Pos: 59, Line: 2, Column: 39, End: 68}},
+	}
+
+	for _, test := range tests {
+		if test.loc != test.expected {
+			t.Errorf("Unexpected %s location\nexpected\n\t%+v\ngot\n\t%+v", test.name, test.expected, test.loc)
+		}
+	}
+}
+
+func TestParseAllErrorsDeduplication(t *testing.T) {
+	t.Parallel()
+
+	p := new("")
+	p.recovering = true
+
+	p.addError(&Error{Message: "Unclosed block", Pos: 3, Line: 1})
+	p.addError(&Error{Message: "Unexpected inverse", Pos: 3, Line: 1})
+	p.addError(&Error{Message: "Unclosed block", Pos: 3, Line: 1})
+
+	// distinct errors at the same position are kept, identical ones are not
+	if len(p.errors) != 2 {
+		t.Errorf("Unexpected errors: %q", p.errors)
+	}
+}
+
+func TestPrintWithLocations(t *testing.T) {
+	t.Parallel()
+
+	program, err := Parse("Hi {{name}}\n{{! bye }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "CONTENT[ 'Hi ' ] @1:1 [0:3]\n" +
+		"{{ PATH:name [] }} @1:4 [3:11]\n" +
+		"CONTENT[ '\n' ] @1:12 [11:12]\n" +
+		"{{! ' bye ' }} @2:1 [12:22]\n"
+
+	if output := ast.PrintWithLocations(program); output != expected {
+		t.Errorf("Unexpected output\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		errors []string
+	}{
+		{
+			"valid input",
+			`foo {{bar}}`,
+			"CONTENT[ 'foo ' ]\n{{ PATH:bar [] }}\n",
+			nil,
+		},
+		{
+			"erroneous statements are skipped",
+			"a{{foo =}}b\n{{bar (}}{{baz}}",
+			"CONTENT[ 'a' ]\nCONTENT[ 'b\n' ]\n{{ PATH:baz [] }}\n",
+			[]string{"7:Expecting Close", "19:Expecting ID"},
+		},
+		{
+			"block closed by another name",
+			`{{#if a}}{{foo =}}x{{/each}}`,
+			"BLOCK:\n  PATH:if [PATH:a]\n  PROGRAM:\n    CONTENT[ 'x' ]\n",
+			[]string{"15:Expecting Close", "22:if doesn't match each"},
+		},
+		{
+			"unclosed blocks",
+			`{{#if a}}x{{#each b}}y`,
+			"BLOCK:\n  PATH:if [PATH:a]\n  PROGRAM:\n    CONTENT[ 'x' ]\n    BLOCK:\n      PATH:each [PATH:b]\n      PROGRAM:\n        CONTENT[ 'y' ]\n",
+			[]string{"3:Unclosed block", "13:Unclosed block"},
+		},
+		{
+			"unexpected inverse",
+			`{{^a}}x{{else}}y{{else}}z{{/a}}`,
+			"BLOCK:\n  PATH:a []\n  PROGRAM:\n    CONTENT[ 'y' ]\n  {{^}}\n    CONTENT[ 'x' ]\n",
+			[]string{"16:Unexpected inverse"},
+		},
+		{
+			"stray tokens",
+			`a{{/if}}b{{else}}c`,
+			"CONTENT[ 'a' ]\nCONTENT[ 'b' ]\nCONTENT[ 'c' ]\n",
+			[]string{"1:Syntax error", "9:Syntax error"},
+		},
+		{
+			"duplicate partial parameters",
+			`{{> foo a=1 a=2}}`,
+			"{{> PARTIAL:foo HASH{a=NUMBER{1}, a=NUMBER{2}} }}\n",
+			[]string{"12:Duplicate partial parameter: a"},
+		},
+		{
+			"nothing is parsed after a lexer error",
+			`{{#if a}}foo{{bar "baz}}{{/if}}`,
+			"BLOCK:\n  PATH:if [PATH:a]\n  PROGRAM:\n    CONTENT[ 'foo' ]\n",
+			[]string{"19:Lexer error"},
+		},
+		{
+			"mismatched close in a nested block",
+			`{{#each items}}{{#if a}}x{{/each}}{{/each}}`,
+			"BLOCK:\n  PATH:each [PATH:items]\n  PROGRAM:\n    BLOCK:\n      PATH:if [PATH:a]\n      PROGRAM:\n        CONTENT[ 'x' ]\n",
+			[]string{"28:if doesn't match each"},
+		},
+		{
+			"mismatched close in a nested unclosed block",
+			`{{#each items}}{{#if a}}x{{/each}}`,
+			"BLOCK:\n  PATH:each [PATH:items]\n  PROGRAM:\n    BLOCK:\n      PATH:if [PATH:a]\n      PROGRAM:\n        CONTENT[ 'x' ]\n",
+			[]string{"3:Unclosed block", "28:if doesn't match each"},
+		},
+		{
+			"unexpected inverse in an unclosed block",
+			`{{#if a}}x{{else}}y{{else}}z`,
+			"BLOCK:\n  PATH:if [PATH:a]\n  PROGRAM:\n    CONTENT[ 'x' ]\n  {{^}}\n    CONTENT[ 'y' ]\n",
+			[]string{"3:Unclosed block", "19:Unexpected inverse"},
+		},
+		{
+			"errors before and after a lexer error",
+			`{{foo =}}{{#if a}}{{#each b}}x{{/if}}{{bar "baz}}{{/each}}{{qux =}}`,
+			"BLOCK:\n  PATH:if [PATH:a]\n  PROGRAM:\n    BLOCK:\n      PATH:each [PATH:b]\n      PROGRAM:\n        CONTENT[ 'x' ]\n",
+			[]string{"6:Expecting Close", "33:each doesn't match if", "44:Lexer error"},
+		},
+	}
+
+	for _, test := range tests {
+		program, errs := ParseAll(test.input)
+
+		if output := ast.Print(program); output != test.output {
+			t.Errorf("Test '%s' failed - Incorrect AST\ninput:\n\t'%s'\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+
+		if len(errs) != len(test.errors) {
+			t.Errorf("Test '%s' failed - Expected %d errors, got: %q", test.name, len(test.errors), errs)
+			continue
+		}
+
+		for i, err := range errs {
+			if got := fmt.Sprintf("%d:%s", err.Pos, err.Message); !strings.HasPrefix(got, test.errors[i]) {
+				t.Errorf("Test '%s' failed - Incorrect error\nexpected\n\t%q\ngot\n\t%q", test.name, test.errors[i], got)
+			}
+		}
+	}
+}
@@ -155,7 +155,7 @@ func isNextWhitespaceProgram(body []ast.Node, i int, isRoot bool) bool {
 		}
 
 		r := rNextWhitespaceEnd
-		if (i+2 > len(body)) || !isRoot {
+		if (i+2 < len(body)) || !isRoot {
 			r = rNextWhitespace
 		}
 
@@ -211,7 +211,7 @@ func (v *whitespaceVisitor) VisitProgram(program *ast.Program) any {
 			}
 		}
 
-		if b, ok := current.(*ast.BlockStatement); ok {
+		if b, ok := blockOf(current); ok {
 			if openStandalone {
 				prog := b.Program
 				if prog == nil {
@@ -319,10 +319,45 @@ func (v *whitespaceVisitor) VisitBlock(block *ast.BlockStatement) any {
 	return strip
 }
 
+func (v *whitespaceVisitor) VisitDecoratorBlock(block *ast.DecoratorBlock) any {
+	b, _ := blockOf(block)
+
+	return v.VisitBlock(b)
+}
+
+// blockOf returns given node as a block statement, if it is a block, a decorator block or a partial block
+func blockOf(node ast.Node) (*ast.BlockStatement, bool) {
+	switch n := node.(type) {
+	case *ast.BlockStatement:
+		return n, true
+	case *ast.PartialStatement:
+		if n.Program != nil {
+			return &ast.BlockStatement{
+				Program:    n.Program,
+				OpenStrip:  n.Strip,
+				CloseStrip: n.CloseStrip,
+			}, true
+		}
+	case *ast.DecoratorBlock:
+		return &ast.BlockStatement{
+			Expression: n.Expression,
+			Program:    n.Program,
+			OpenStrip:  n.OpenStrip,
+			CloseStrip: n.CloseStrip,
+		}, true
+	}
+
+	return nil, false
+}
+
 func (v *whitespaceVisitor) VisitMustache(mustache *ast.MustacheStatement) any {
 	return mustache.Strip
 }
 
+func (v *whitespaceVisitor) VisitDecorator(node *ast.Decorator) any {
+	return node.Strip
+}
+
 func _inlineStandalone(strip *ast.Strip) any {
 	return &ast.Strip{
 		Open:             strip.Open,
@@ -332,6 +367,11 @@ func _inlineStandalone(strip *ast.Strip) any {
 }
 
 func (v *whitespaceVisitor) VisitPartial(node *ast.PartialStatement) any {
+	if b, ok := blockOf(node); ok {
+		// partial block
+		return v.VisitBlock(b)
+	}
+
 	strip := node.Strip
 	if strip == nil {
 		strip = &ast.Strip{}
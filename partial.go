@@ -2,100 +2,211 @@ package raymond
 
 import (
 	"fmt"
+	"io/fs"
+	"path"
 	"sync"
+
+	"github.com/yoinkai/raymond/v2/ast"
 )
 
 // partial represents a partial template
 type partial struct {
 	name   string
 	source string
-	tpl    *Template
-}
-
-// partials stores all global partials
-var partials map[string]*partial
 
-// protects global partials
-var partialsMutex sync.RWMutex
+	// environment the partial source is parsed with
+	env *Environment
 
-func init() {
-	partials = make(map[string]*partial)
+	// parsed template, lazily set from source
+	mutex sync.Mutex
+	tpl   *Template
 }
 
-// newPartial instanciates a new partial
-func newPartial(name string, source string, tpl *Template) *partial {
+// newPartial instanciates a new partial, given source is parsed with given environment when the partial is first
+// rendered, unless an already parsed template is given
+func newPartial(name string, source string, env *Environment, tpl *Template) *partial {
 	return &partial{
 		name:   name,
 		source: source,
+		env:    env,
 		tpl:    tpl,
 	}
 }
 
-// RegisterPartial registers a global partial. That partial will be available to all templates.
+// RegisterPartial registers a global partial. That partial will be available to all templates of the default
+// environment.
 func RegisterPartial(name string, source string) {
-	partialsMutex.Lock()
-	defer partialsMutex.Unlock()
-
-	if partials[name] != nil {
-		panic(fmt.Errorf("partial already registered: %s", name))
-	}
-
-	partials[name] = newPartial(name, source, nil)
+	defaultEnv.RegisterPartial(name, source)
 }
 
-// RegisterPartials registers several global partials. Those partials will be available to all templates.
+// RegisterPartials registers several global partials. Those partials will be available to all templates of the
+// default environment.
 func RegisterPartials(partials map[string]string) {
-	for name, p := range partials {
-		RegisterPartial(name, p)
-	}
+	defaultEnv.RegisterPartials(partials)
 }
 
-// RegisterPartialTemplate registers a global partial with given parsed template. That partial will be available to all templates.
-func RegisterPartialTemplate(name string, tpl *Template) {
-	partialsMutex.Lock()
-	defer partialsMutex.Unlock()
-
-	if partials[name] != nil {
-		panic(fmt.Errorf("partial already registered: %s", name))
-	}
+// RegisterPartialsFS reads the files matching given pattern in fsys, and registers them as global partials.
+//
+// See Template.RegisterPartialsFS() for pattern, extensions and partials names.
+func RegisterPartialsFS(fsys fs.FS, pattern string, extensions ...string) error {
+	return defaultEnv.RegisterPartialsFS(fsys, pattern, extensions...)
+}
 
-	partials[name] = newPartial(name, "", tpl)
+// RegisterPartialTemplate registers a global partial with given parsed template. That partial will be available to all templates of the default environment.
+func RegisterPartialTemplate(name string, tpl *Template) {
+	defaultEnv.RegisterPartialTemplate(name, tpl)
 }
 
 // RemovePartial removes the partial registered under the given name. The partial will not be available globally anymore. This does not affect partials registered on a specific template.
 func RemovePartial(name string) {
-	partialsMutex.Lock()
-	defer partialsMutex.Unlock()
-
-	delete(partials, name)
+	defaultEnv.RemovePartial(name)
 }
 
 // RemoveAllPartials removes all globally registered partials. This does not affect partials registered on a specific template.
 func RemoveAllPartials() {
-	partialsMutex.Lock()
-	defer partialsMutex.Unlock()
+	defaultEnv.RemoveAllPartials()
+}
+
+// template returns parsed partial template
+func (p *partial) template() (*Template, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.tpl == nil {
+		tpl, err := p.env.parse(p.name, p.source)
+		if err != nil {
+			return nil, err
+		}
 
-	partials = make(map[string]*partial)
+		p.tpl = tpl
+	}
+
+	return p.tpl, nil
 }
 
-// findPartial finds a registered global partial
-func findPartial(name string) *partial {
-	partialsMutex.RLock()
-	defer partialsMutex.RUnlock()
+// partialBlockName is the name of the partial that renders the content of current partial block
+const partialBlockName = "@partial-block"
+
+// inlinePartials returns the inline partials defined in given program of given template, or nil if none
+//
+// An inline partial is evaluated with the compiled programs and the environment of the template that defines it, and
+// its errors are located in the source of that template.
+func inlinePartials(node *ast.Program, parent *Template, programs compiledPrograms) map[string]*partial {
+	var result map[string]*partial
+
+	for _, stmt := range node.Body {
+		block, ok := stmt.(*ast.DecoratorBlock)
+		if !ok {
+			continue
+		}
+
+		name, ok := inlinePartialName(block)
+		if !ok {
+			continue
+		}
 
-	return partials[name]
+		tpl := newTemplate(parent.source)
+		tpl.name = parent.name
+		tpl.program = block.Program
+		tpl.programs = programs
+		tpl.env = parent.env
+
+		if result == nil {
+			result = make(map[string]*partial)
+		}
+
+		result[name] = newPartial(name, "", parent.env, tpl)
+	}
+
+	return result
 }
 
-// template returns parsed partial template
-func (p *partial) template() (*Template, error) {
-	if p.tpl == nil {
-		var err error
+// inlinePartialName returns the name of the partial defined by given decorator block, if that is an inline partial
+// definition: {{#*inline "name"}}
+func inlinePartialName(node *ast.DecoratorBlock) (string, bool) {
+	if (node.Expression.HelperName() != "inline") || (len(node.Expression.Params) != 1) {
+		return "", false
+	}
+
+	name, ok := node.Expression.Params[0].(*ast.StringLiteral)
+	if !ok {
+		return "", false
+	}
+
+	return name.Value, true
+}
+
+// defaultPartialsExtensions are the extensions of partials files found in directories, when none are specified
+var defaultPartialsExtensions = []string{".hbs", ".handlebars"}
+
+// readPartialsFS reads the partials files matching given pattern in fsys, and returns their sources by partial name
+func readPartialsFS(fsys fs.FS, pattern string, extensions []string) (map[string]string, error) {
+	if len(extensions) == 0 {
+		extensions = defaultPartialsExtensions
+	}
+
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+
+	add := func(filePath string) error {
+		name := filePath[:len(filePath)-len(path.Ext(filePath))]
+		if _, ok := result[name]; ok {
+			return fmt.Errorf("several files for partial %s", name)
+		}
+
+		b, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
 
-		p.tpl, err = Parse(p.source)
+		result[name] = string(b)
+
+		return nil
+	}
+
+	for _, match := range matches {
+		info, err := fs.Stat(fsys, match)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if err := add(match); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		// register directory files with given extensions
+		err = fs.WalkDir(fsys, match, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !hasExtension(filePath, extensions) {
+				return err
+			}
+
+			return add(filePath)
+		})
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return p.tpl, nil
+	return result, nil
+}
+
+// hasExtension returns true if given file path has one of given extensions
+func hasExtension(filePath string, extensions []string) bool {
+	ext := path.Ext(filePath)
+
+	for _, extension := range extensions {
+		if ext == extension {
+			return true
+		}
+	}
+
+	return false
 }
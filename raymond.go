@@ -3,16 +3,10 @@ package raymond
 
 import "github.com/sirupsen/logrus"
 
-var log *logrus.Entry
-
-func init() {
-	log = logrus.NewEntry(logrus.StandardLogger())
-}
-
 // SetLogger allows the user to set a customer logger adding the ability to add custom fields to
-// the log entries.
+// the log entries. It sets the logger of the default environment.
 func SetLogger(entry *logrus.Entry) {
-	log = entry
+	defaultEnv.SetLogger(entry)
 }
 
 // Render parses a template and evaluates it with given context
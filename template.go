@@ -1,7 +1,12 @@
 package raymond
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"reflect"
 	"runtime"
@@ -13,51 +18,102 @@ import (
 
 // Template represents a handlebars template.
 type Template struct {
-	source   string
-	program  *ast.Program
-	helpers  map[string]reflect.Value
-	partials map[string]*partial
-	mutex    sync.RWMutex // protects helpers and partials
+	name       string
+	source     string
+	program    *ast.Program
+	helpers    map[string]reflect.Value
+	partials   map[string]*partial
+	decorators map[string]Decorator
+	opts       execOptions
+	parseOpts  parseOptions
+	mutex      sync.RWMutex // protects helpers, partials, decorators, opts and htmlEscapers
+
+	// environment providing helpers, partials and decorators not registered on template
+	env *Environment
+
+	// contextual HTML escaping enabled
+	html bool
+
+	// mustaches escapers for contextual HTML escaping, computed once
+	htmlEscapers htmlEscapers
+
+	// program lowered to closures
+	programs compiledPrograms
+}
+
+// execOptions represents template evaluation options.
+type execOptions struct {
+	// fail on missing fields
+	strict bool
+
+	// fail on missing objects when traversing paths
+	assumeObjects bool
+
+	// resolve paths by trying all ancestor contexts
+	compat bool
+
+	// provide params and hash ids to helpers, and set @contextPath
+	trackIDs bool
+
+	// pass paths instead of their values to helpers
+	stringParams bool
+
+	// do not indent standalone partials
+	preventIndent bool
+
+	// mustaches escaper, Escape() is used if nil
+	escaper Escaper
 }
 
 // newTemplate instanciate a new template without parsing it
 func newTemplate(source string) *Template {
 	return &Template{
-		source:   source,
-		helpers:  make(map[string]reflect.Value),
-		partials: make(map[string]*partial),
+		source:     source,
+		helpers:    make(map[string]reflect.Value),
+		partials:   make(map[string]*partial),
+		decorators: make(map[string]Decorator),
+		env:        defaultEnv,
 	}
 }
 
 // Parse instanciates a template by parsing given source.
 func Parse(source string) (*Template, error) {
-	tpl := newTemplate(source)
-
-	// parse template
-	if err := tpl.parse(); err != nil {
-		return nil, err
-	}
-
-	return tpl, nil
+	return defaultEnv.Parse(source)
 }
 
 // MustParse instanciates a template by parsing given source. It panics on error.
 func MustParse(source string) *Template {
-	result, err := Parse(source)
-	if err != nil {
-		panic(err)
-	}
-	return result
+	return defaultEnv.MustParse(source)
+}
+
+// ParseHTML instanciates a template by parsing given source, with contextual HTML escaping enabled.
+//
+// Each mustache is escaped according to the HTML context it appears in: element text, attribute value, URL, JS code,
+// JS string, CSS code, CSS string... A mustache in an HTML, JS or CSS comment outputs nothing. An error is returned if
+// a mustache appears where no safe escaping exists, like in a tag name, if a block does not end in the context it
+// started in, if a partial is called outside of element text, or if the template ends in a non-text context.
+//
+// Partials are analyzed the same way when they are called, and must start and end in element text context.
+//
+// As with Parse(), SafeString values and triple-stash mustaches {{{ }}} are never escaped. The escaper set with
+// SetEscaper() is not used.
+func ParseHTML(source string) (*Template, error) {
+	return defaultEnv.ParseHTML(source)
+}
+
+// MustParseHTML instanciates a template by parsing given source, with contextual HTML escaping enabled. It panics on error.
+func MustParseHTML(source string) *Template {
+	return defaultEnv.MustParseHTML(source)
 }
 
 // ParseFile reads given file and returns parsed template.
 func ParseFile(filePath string) (*Template, error) {
-	b, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
+	return defaultEnv.ParseFile(filePath)
+}
 
-	return Parse(string(b))
+// ParseFS reads given file from fsys and returns parsed template.
+func ParseFS(fsys fs.FS, name string) (*Template, error) {
+	return defaultEnv.ParseFS(fsys, name)
 }
 
 // parse parses the template
@@ -67,10 +123,23 @@ func (tpl *Template) parse() error {
 	if tpl.program == nil {
 		var err error
 
-		tpl.program, err = parser.Parse(tpl.source)
+		program, err := parser.Parse(tpl.source)
 		if err != nil {
+			if perr, ok := err.(*parser.Error); ok {
+				return tpl.newParseError(perr.Pos, perr.Message)
+			}
+
 			return err
 		}
+
+		if tpl.parseOpts.knownHelpersOnly {
+			if err := checkHelpers(tpl, program); err != nil {
+				return err
+			}
+		}
+
+		tpl.programs = compile(tpl, program)
+		tpl.program = program
 	}
 
 	return nil
@@ -80,22 +149,145 @@ func (tpl *Template) parse() error {
 func (tpl *Template) Clone() *Template {
 	result := newTemplate(tpl.source)
 
+	result.name = tpl.name
 	result.program = tpl.program
+	result.programs = tpl.programs
+	result.env = tpl.env
+	result.parseOpts = tpl.parseOpts
 
 	tpl.mutex.RLock()
 	defer tpl.mutex.RUnlock()
 
+	result.opts = tpl.opts
+	result.html = tpl.html
+	result.htmlEscapers = tpl.htmlEscapers
+
 	for name, helper := range tpl.helpers {
 		result.RegisterHelper(name, helper.Interface())
 	}
 
 	for name, partial := range tpl.partials {
-		result.addPartial(name, partial.source, partial.tpl)
+		// a partial is not modified once parsed, so it can be shared
+		result.partials[name] = partial
+	}
+
+	for name, decorator := range tpl.decorators {
+		result.RegisterDecorator(name, decorator)
 	}
 
 	return result
 }
 
+// SetStrict enables or disables strict mode for that template.
+//
+// In strict mode, evaluation fails with an error instead of rendering an empty string when a path at the root of an
+// expression, like `{{user.firstName}}` or `{{#user}}`, can't be resolved. Parameters of helpers may still resolve to
+// nothing, but like in assume objects mode the objects they are looked up on must exist.
+func (tpl *Template) SetStrict(strict bool) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.strict = strict
+}
+
+// SetAssumeObjects enables or disables assume objects mode for that template.
+//
+// In that mode, evaluation fails with an error when a path traverses an object that can't be resolved, like `user` in
+// `{{user.firstName}}`. A missing last part of a path still renders an empty string. That is a subset of strict mode.
+func (tpl *Template) SetAssumeObjects(assumeObjects bool) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.assumeObjects = assumeObjects
+}
+
+// SetCompat enables or disables compat mode for that template.
+//
+// By default, a path like `{{user.name}}` is resolved with the first context, starting from current one, that has a
+// `user` field, so it renders an empty string if that `user` has no `name` field. In compat mode, ancestor contexts are
+// tried until one resolves the whole path, like Mustache does with templates migrated to handlebars.
+func (tpl *Template) SetCompat(compat bool) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.compat = compat
+}
+
+// SetTrackIDs enables or disables trackIds mode for that template.
+//
+// In that mode, helpers get the original paths of their params and hash values with Options.ParamID() and
+// Options.HashID(), and the `each` and `with` helpers set the `@contextPath` private variable to the path of the context
+// they evaluate their block with, like `users.0.addresses`.
+func (tpl *Template) SetTrackIDs(trackIDs bool) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.trackIDs = trackIDs
+}
+
+// SetStringParams enables or disables stringParams mode for that template.
+//
+// In that mode, a helper param or hash value that is a path, like `user.name` in `{{t user.name}}`, is passed as the
+// path string instead of its value. The type of params and hash values is available with Options.ParamType() and
+// Options.HashType(). Subexpressions are still evaluated.
+func (tpl *Template) SetStringParams(stringParams bool) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.stringParams = stringParams
+}
+
+// SetPreventIndent enables or disables preventIndent mode for that template.
+//
+// By default, the output of a standalone partial, alone on its line, is indented like the partial call. In
+// preventIndent mode, partials called by that template are not indented, and the whitespace before the call is output
+// as is. Set that mode on a template registered with RegisterPartialTemplate() to never indent that partial, which is
+// useful for partials that output pre-formatted text.
+func (tpl *Template) SetPreventIndent(preventIndent bool) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.preventIndent = preventIndent
+}
+
+// SetEscaper sets the function used to escape mustaches results for that template.
+//
+// By default, Escape() is used. Built-in escapers are EscapeHTML(), NoEscape(), EscapeJSON() and EscapeURLQuery(), but
+// any function can be used. Set nil to restore default escaper.
+//
+// Note that SafeString values and triple-stash mustaches {{{ }}} are never escaped.
+func (tpl *Template) SetEscaper(escaper Escaper) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.opts.escaper = escaper
+}
+
+// contextEscapers returns the mustaches escapers for contextual HTML escaping, computing them if necessary
+func (tpl *Template) contextEscapers() (htmlEscapers, error) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	if tpl.htmlEscapers == nil {
+		escapers, err := computeHTMLEscapers(tpl, tpl.program)
+		if err != nil {
+			return nil, err
+		}
+
+		tpl.htmlEscapers = escapers
+	}
+
+	return tpl.htmlEscapers, nil
+}
+
+// execOptions returns a copy of template evaluation options
+func (tpl *Template) execOptions() execOptions {
+	tpl.mutex.RLock()
+	defer tpl.mutex.RUnlock()
+
+	return tpl.opts
+}
+
 func (tpl *Template) findHelper(name string) reflect.Value {
 	tpl.mutex.RLock()
 	defer tpl.mutex.RUnlock()
@@ -116,6 +308,8 @@ func (tpl *Template) RegisterHelper(name string, helper any) {
 	ensureValidHelper(name, val)
 
 	tpl.helpers[name] = val
+
+	helpersGen.Add(1)
 }
 
 // RegisterHelpers registers several helpers for that template.
@@ -125,6 +319,39 @@ func (tpl *Template) RegisterHelpers(helpers map[string]any) {
 	}
 }
 
+// RegisterLayoutHelpers registers the extend, block and content layout helpers for that template.
+func (tpl *Template) RegisterLayoutHelpers() {
+	tpl.RegisterHelpers(layoutHelpers())
+}
+
+func (tpl *Template) findDecorator(name string) Decorator {
+	tpl.mutex.RLock()
+	defer tpl.mutex.RUnlock()
+
+	return tpl.decorators[name]
+}
+
+// RegisterDecorator registers a decorator for that template.
+func (tpl *Template) RegisterDecorator(name string, decorator Decorator) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	if tpl.decorators[name] != nil {
+		panic(fmt.Sprintf("decorator %s already registered", name))
+	}
+
+	ensureValidDecorator(name, decorator)
+
+	tpl.decorators[name] = decorator
+}
+
+// RegisterDecorators registers several decorators for that template.
+func (tpl *Template) RegisterDecorators(decorators map[string]Decorator) {
+	for name, decorator := range decorators {
+		tpl.RegisterDecorator(name, decorator)
+	}
+}
+
 func (tpl *Template) addPartial(name string, source string, template *Template) {
 	tpl.mutex.Lock()
 	defer tpl.mutex.Unlock()
@@ -133,7 +360,7 @@ func (tpl *Template) addPartial(name string, source string, template *Template)
 		panic(fmt.Sprintf("partial %s already registered", name))
 	}
 
-	tpl.partials[name] = newPartial(name, source, template)
+	tpl.partials[name] = newPartial(name, source, tpl.env, template)
 }
 
 func (tpl *Template) findPartial(name string) *partial {
@@ -184,6 +411,25 @@ func (tpl *Template) RegisterPartialFiles(filePaths ...string) error {
 	return nil
 }
 
+// RegisterPartialsFS reads the files matching given pattern in fsys, and registers them as partials for that template.
+//
+// The pattern syntax is the one of fs.Glob(). A matching file is registered whatever its extension, and the files of a
+// matching directory and of its subdirectories are registered if they have one of given extensions, or the .hbs or
+// .handlebars extension if none are given.
+//
+// The partial name is the file path in fsys, without extension, like `emails/header` for `emails/header.hbs`. Use
+// fs.Sub() to strip a directory prefix from names.
+func (tpl *Template) RegisterPartialsFS(fsys fs.FS, pattern string, extensions ...string) error {
+	sources, err := readPartialsFS(fsys, pattern, extensions)
+	if err != nil {
+		return err
+	}
+
+	tpl.RegisterPartials(sources)
+
+	return nil
+}
+
 // RegisterPartialTemplate registers an already parsed partial for that template.
 func (tpl *Template) RegisterPartialTemplate(name string, template *Template) {
 	tpl.addPartial(name, "", template)
@@ -205,6 +451,66 @@ func (tpl *Template) MustExec(ctx any) string {
 
 // ExecWith evaluates template with given context and private data frame.
 func (tpl *Template) ExecWith(ctx any, privData *DataFrame) (result string, err error) {
+	return tpl.ExecContextWith(context.Background(), ctx, privData)
+}
+
+// ExecContext evaluates template with given context, and stops evaluation with an error as soon as execCtx is cancelled
+// or its deadline passes.
+//
+// The execCtx is available to helpers with Options.Context().
+func (tpl *Template) ExecContext(execCtx context.Context, ctx any) (string, error) {
+	return tpl.ExecContextWith(execCtx, ctx, nil)
+}
+
+// ExecContextWith evaluates template with given context and private data frame, and stops evaluation with an error as
+// soon as execCtx is cancelled or its deadline passes.
+func (tpl *Template) ExecContextWith(execCtx context.Context, ctx any, privData *DataFrame) (result string, err error) {
+	buf := new(bytes.Buffer)
+
+	if err = tpl.exec(execCtx, buf, ctx, privData); err != nil {
+		return
+	}
+
+	result = buf.String()
+
+	// named return values
+	return
+}
+
+// ExecTo evaluates template with given context and writes the result to given writer.
+//
+// Output is streamed to the writer as the template is evaluated, so the writer may have received a partial result if an error is returned.
+func (tpl *Template) ExecTo(w io.Writer, ctx any) error {
+	return tpl.ExecToWith(w, ctx, nil)
+}
+
+// ExecToWith evaluates template with given context and private data frame, and writes the result to given writer.
+//
+// Output is streamed to the writer as the template is evaluated, so the writer may have received a partial result if an error is returned.
+func (tpl *Template) ExecToWith(w io.Writer, ctx any, privData *DataFrame) error {
+	return tpl.ExecToContextWith(context.Background(), w, ctx, privData)
+}
+
+// ExecToContext evaluates template with given context and writes the result to given writer. Evaluation stops with an
+// error as soon as execCtx is cancelled or its deadline passes.
+func (tpl *Template) ExecToContext(execCtx context.Context, w io.Writer, ctx any) error {
+	return tpl.ExecToContextWith(execCtx, w, ctx, nil)
+}
+
+// ExecToContextWith evaluates template with given context and private data frame, and writes the result to given writer.
+// Evaluation stops with an error as soon as execCtx is cancelled or its deadline passes.
+func (tpl *Template) ExecToContextWith(execCtx context.Context, w io.Writer, ctx any, privData *DataFrame) error {
+	out := bufio.NewWriter(w)
+
+	if err := tpl.exec(execCtx, out, ctx, privData); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
+
+// exec evaluates template with given context and private data frame, and writes the result to given output
+func (tpl *Template) exec(execCtx context.Context, out writer, ctx any, privData *DataFrame) (err error) {
 	defer errRecover(&err)
 
 	// parses template if necessary
@@ -214,10 +520,18 @@ func (tpl *Template) ExecWith(ctx any, privData *DataFrame) (result string, err
 	}
 
 	// setup visitor
-	v := newEvalVisitor(tpl, ctx, privData)
+	v := newEvalVisitor(execCtx, tpl, ctx, privData, out)
+
+	defer v.errRecover(&err)
+
+	if tpl.html {
+		if v.htmlEscapers, err = tpl.contextEscapers(); err != nil {
+			return
+		}
+	}
 
 	// visit AST
-	result, _ = tpl.program.Accept(v).(string)
+	tpl.program.Accept(v)
 
 	// named return values
 	return
@@ -1,8 +1,14 @@
 package raymond
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 const sourceBasic = `<div class="entry">
@@ -46,6 +52,102 @@ func TestParse(t *testing.T) {
 	}
 }
 
+var templatesFS = fstest.MapFS{
+	"page.hbs":                  {Data: []byte(`{{> layout/header}}{{> emails/welcome}}{{> emails/nested/footer}}`)},
+	"layout/header.hbs":         {Data: []byte(`<h1>{{title}}</h1>`)},
+	"emails/welcome.hbs":        {Data: []byte(`Welcome {{name}}`)},
+	"emails/nested/footer.hbs":  {Data: []byte(`!`)},
+	"emails/README.md":          {Data: []byte(`not a partial`)},
+	"other/welcome.handlebars":  {Data: []byte(`{{name}}`)},
+	"other/welcome.mustache":    {Data: []byte(`{{name}}`)},
+	"other/nested/welcome.html": {Data: []byte(`<p>{{name}}</p>`)},
+}
+
+func TestParseFS(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseFS(templatesFS, "emails/welcome.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output := tpl.MustExec(map[string]string{"name": "Jo"}); output != "Welcome Jo" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if _, err := ParseFS(templatesFS, "missing.hbs"); err == nil {
+		t.Errorf("Error expected for a missing file")
+	}
+}
+
+func TestRegisterPartialsFS(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseFS(templatesFS, "page.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tpl.RegisterPartialsFS(templatesFS, "layout"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tpl.RegisterPartialsFS(templatesFS, "email*"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := tpl.partials["emails/README"]; ok {
+		t.Errorf("File without partial extension registered")
+	}
+
+	output := tpl.MustExec(map[string]string{"title": "Hi", "name": "Jo"})
+	if output != "<h1>Hi</h1>Welcome Jo!" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestRegisterPartialsFSExtensions(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{> other/welcome}}{{> other/nested/welcome}}`)
+	if err := tpl.RegisterPartialsFS(templatesFS, "other", ".mustache", ".html"); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := tpl.MustExec(map[string]string{"name": "Jo"}); output != "Jo<p>Jo</p>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestRegisterPartialsFSFile(t *testing.T) {
+	t.Parallel()
+
+	// a matching file is registered whatever its extension
+	tpl := MustParse(`{{> emails/README}}`)
+	if err := tpl.RegisterPartialsFS(templatesFS, "emails/*.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := tpl.MustExec(nil); output != "not a partial" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestRegisterPartialsFSErrors(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(``)
+
+	if err := tpl.RegisterPartialsFS(templatesFS, "["); err == nil {
+		t.Errorf("Error expected for a malformed pattern")
+	}
+
+	err := tpl.RegisterPartialsFS(templatesFS, "other/*")
+	if (err == nil) || !strings.Contains(err.Error(), "several files for partial other/welcome") {
+		t.Errorf("Unexpected error for duplicate partials: %v", err)
+	}
+}
+
 func TestClone(t *testing.T) {
 	t.Parallel()
 
@@ -76,6 +178,162 @@ func TestClone(t *testing.T) {
 	}
 }
 
+// failingWriter fails once more than limit bytes were written
+type failingWriter struct {
+	limit   int
+	written int
+}
+
+var errFailingWriter = errors.New("writer is full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errFailingWriter
+	}
+
+	w.written += len(p)
+
+	return len(p), nil
+}
+
+func TestExecTo(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#each items}}{{> item}}{{/each}}")
+	tpl.RegisterPartial("item", "<li>{{name}}</li>")
+
+	ctx := map[string]any{
+		"items": []map[string]string{{"name": "foo"}, {"name": "<bar>"}},
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.ExecTo(&buf, ctx); err != nil {
+		t.Fatalf("Failed to render template: %s", err)
+	}
+
+	if expected := "<li>foo</li><li>&lt;bar&gt;</li>"; buf.String() != expected {
+		t.Errorf("Unexpected output: %q, expected %q", buf.String(), expected)
+	}
+
+	if str := tpl.MustExec(ctx); str != buf.String() {
+		t.Errorf("ExecTo() output %q differs from Exec() output %q", buf.String(), str)
+	}
+}
+
+func TestExecToWriteError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#each items}}{{this}}{{/each}}")
+
+	items := make([]string, 10000)
+	for i := range items {
+		items[i] = "foobar"
+	}
+
+	err := tpl.ExecTo(&failingWriter{limit: 100}, map[string]any{"items": items})
+	if !errors.Is(err, errFailingWriter) {
+		t.Errorf("Expected write error, got: %v", err)
+	}
+}
+
+func TestExecContext(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#each items}}{{slow this}}{{/each}}")
+
+	calls := 0
+	execCtx, cancel := context.WithCancel(context.Background())
+
+	tpl.RegisterHelper("slow", func(nb int) string {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return ""
+	})
+
+	_, err := tpl.ExecContext(execCtx, map[string]any{"items": []int{1, 2, 3, 4, 5}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected evaluation to be cancelled, got: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Evaluation should have stopped after 2 helper calls, got %d calls", calls)
+	}
+}
+
+func TestExecContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#items}}{{this}}{{/items}}")
+
+	execCtx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	if _, err := tpl.ExecContext(execCtx, map[string]any{"items": []int{1, 2, 3}}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.ExecToContext(execCtx, &buf, map[string]any{"items": []int{1, 2, 3}}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline error, got: %v", err)
+	}
+}
+
+func TestPreventIndent(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("<div>\n  {{> code}}\n  <hr>\n  {{> list}}\n</div>")
+	tpl.RegisterPartial("list", "<ul>\n  <li>a</li>\n</ul>\n")
+
+	code := MustParse("<pre>\nfunc main() {\n}\n</pre>\n")
+	code.SetPreventIndent(true)
+	tpl.RegisterPartialTemplate("code", code)
+
+	if output, expected := tpl.MustExec(nil), "<div>\n  <pre>\nfunc main() {\n}\n</pre>\n  <hr>\n  <ul>\n    <li>a</li>\n  </ul>\n</div>"; output != expected {
+		t.Errorf("Unexpected output with a partial preventing indent\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+
+	tpl.SetPreventIndent(true)
+
+	if output, expected := tpl.MustExec(nil), "<div>\n  <pre>\nfunc main() {\n}\n</pre>\n  <hr>\n  <ul>\n  <li>a</li>\n</ul>\n</div>"; output != expected {
+		t.Errorf("Unexpected output with prevent indent\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+
+	// partial blocks
+	tpl = MustParse("{{#> box}}\n<pre>\n x\n</pre>\n{{/box}}")
+	tpl.RegisterPartial("box", "<div>\n  {{> @partial-block}}\n</div>")
+
+	if output, expected := tpl.MustExec(nil), "<div>\n  <pre>\n   x\n  </pre>\n</div>"; output != expected {
+		t.Errorf("Unexpected output with an indented partial block\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+
+	tpl.SetPreventIndent(true)
+
+	if output, expected := tpl.MustExec(nil), "<div>\n  <pre>\n x\n</pre>\n</div>"; output != expected {
+		t.Errorf("Unexpected output with prevent indent and a partial block\nexpected\n\t%q\ngot\n\t%q", expected, output)
+	}
+}
+
+func ExampleTemplate_ExecTo() {
+	source := "<h1>{{title}}</h1><p>{{body.content}}</p>"
+
+	ctx := map[string]any{
+		"title": "foo",
+		"body":  map[string]string{"content": "bar"},
+	}
+
+	// parse template
+	tpl := MustParse(source)
+
+	// evaluate template with context and write result to stdout
+	if err := tpl.ExecTo(os.Stdout, ctx); err != nil {
+		panic(err)
+	}
+
+	// Output: <h1>foo</h1><p>bar</p>
+}
+
 func ExampleTemplate_Exec() {
 	source := "<h1>{{title}}</h1><p>{{body.content}}</p>"
 
@@ -31,6 +31,15 @@ func IsTrue(obj any) bool {
 	return thruth
 }
 
+// IsNil returns true if given value is nil, or a nil pointer, map, slice, interface, channel or function.
+//
+// It is used by code generated with raymond-gen to check pointer fields before selecting their own fields.
+func IsNil(value any) bool {
+	val := reflect.ValueOf(value)
+
+	return !val.IsValid() || (canBeNil(val.Type()) && val.IsNil())
+}
+
 // isTrueValue reports whether the value is 'true', in the sense of not the zero of its type,
 // and whether the value has a meaningful truth value
 //